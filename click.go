@@ -0,0 +1,31 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+
+	modules "github.com/andir/go3status/modules"
+)
+
+// readClickEvents parses the infinite JSON array i3bar writes to our stdin
+// and forwards every click event. It returns when the stream ends or can't
+// be parsed anymore.
+func readClickEvents(r io.Reader, events chan<- modules.ClickEvent) {
+	decoder := json.NewDecoder(r)
+
+	// the stream starts with the opening bracket of the array, the
+	// decoder takes care of the commas between the events afterwards
+	if _, err := decoder.Token(); err != nil {
+		log.Error("Failed to read click events: " + err.Error())
+		return
+	}
+
+	for decoder.More() {
+		var event modules.ClickEvent
+		if err := decoder.Decode(&event); err != nil {
+			log.Error("Failed to parse click event: " + err.Error())
+			return
+		}
+		events <- event
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	modules "github.com/andir/go3status/modules"
+)
+
+func TestReadClickEvents(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []modules.ClickEvent
+	}{
+		{name: "empty", input: ""},
+		{name: "no events", input: "[\n"},
+		{
+			name:  "events",
+			input: "[\n{\"name\":\"a\",\"button\":1,\"x\":10,\"y\":20}\n,{\"name\":\"b\",\"instance\":\"i\",\"button\":3,\"modifiers\":[\"Shift\"]}\n",
+			want: []modules.ClickEvent{
+				{Name: "a", Button: 1, X: 10, Y: 20},
+				{Name: "b", Instance: "i", Button: 3, Modifiers: []string{"Shift"}},
+			},
+		},
+		{
+			name:  "garbage stops reading",
+			input: "[{\"name\":\"a\",\"button\":1},garbage,{\"name\":\"b\"}",
+			want:  []modules.ClickEvent{{Name: "a", Button: 1}},
+		},
+	}
+	for _, test := range tests {
+		events := make(chan modules.ClickEvent)
+		go func() {
+			readClickEvents(strings.NewReader(test.input), events)
+			close(events)
+		}()
+		var got []modules.ClickEvent
+		for event := range events {
+			got = append(got, event)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+// clickableInstance records the clicks it receives.
+type clickableInstance struct {
+	fakeInstance
+	mu     sync.Mutex
+	clicks []modules.ClickEvent
+}
+
+func (c *clickableInstance) Click(event modules.ClickEvent) {
+	c.mu.Lock()
+	c.clicks = append(c.clicks, event)
+	c.mu.Unlock()
+}
+
+func (c *clickableInstance) Clicks() []modules.ClickEvent {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]modules.ClickEvent(nil), c.clicks...)
+}
+
+func TestSchedulerClick(t *testing.T) {
+	a := &clickableInstance{fakeInstance: fakeInstance{name: "a"}}
+	b := &clickableInstance{fakeInstance: fakeInstance{name: "b"}}
+	plain := &fakeInstance{name: "plain"}
+	s := startScheduler(t, fakeConfigured(a, nil), fakeConfigured(b, nil), fakeConfigured(plain, nil))
+	eventually(t, "the first render", func() bool { return s.Store().Complete() })
+
+	// neither of these may panic or reach a or b
+	s.Click(modules.ClickEvent{Name: "plain", Button: 1})
+	s.Click(modules.ClickEvent{Name: "unknown", Button: 1})
+
+	s.Click(modules.ClickEvent{Name: "b", Button: 3})
+	eventually(t, "the click on b", func() bool { return len(b.Clicks()) == 1 })
+	if got := b.Clicks()[0]; got.Button != 3 {
+		t.Errorf("b got %+v", got)
+	}
+	// a click refreshes the block right away
+	eventually(t, "the render after the click", func() bool { return b.Renders() == 2 })
+	if clicks := a.Clicks(); len(clicks) != 0 {
+		t.Errorf("a got clicks %+v", clicks)
+	}
+}
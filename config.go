@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"syscall"
+	"time"
+
+	modules "github.com/andir/go3status/modules"
+	"github.com/andir/go3status/output"
+)
+
+// the newest config version this build understands
+const configVersion = 1
+
+// Config is the top level object of the config file. For backward
+// compatibility the file may also be a bare array of blocks.
+type Config struct {
+	Version int     `json:"version"`
+	General General `json:"general"`
+	// settings applied to every block of a module, keyed by module name
+	Defaults map[string]map[string]interface{} `json:"defaults"`
+	// the blocks in bar order
+	Blocks []map[string]interface{} `json:"blocks"`
+}
+
+// General holds the global settings.
+type General struct {
+	Interval              string    `json:"interval" doc:"emit the bar at least this often, e.g. \"2s\""`
+	Color                 string    `json:"color" doc:"default text color of every block"`
+	Background            string    `json:"background" doc:"default background color of every block"`
+	Markup                string    `json:"markup" allowed:"pango,none" doc:"default markup of every block"`
+	Separator             *bool     `json:"separator" doc:"draw separators after the blocks"`
+	Separator_block_width *int      `json:"separator_block_width" doc:"default gap after every block in pixels"`
+	Error_color           string    `json:"error_color" doc:"default error_color of every block"`
+	Stale_color           string    `json:"stale_color" doc:"default stale_color of every block"`
+	Good_color            string    `json:"good_color" default:"#00FF00" doc:"color of good values, see thresholds"`
+	Degraded_color        string    `json:"degraded_color" default:"#FFFF00" doc:"color of degraded values, see thresholds"`
+	Bad_color             string    `json:"bad_color" default:"#FF0000" doc:"color of bad values, see thresholds"`
+	Log                   LogConfig `json:"log" doc:"level and output of the log"`
+	Output                string    `json:"output" allowed:"i3bar,swaybar,plain,tmux,ansi,lemonbar,dzen2,xmobar,waybar" doc:"format written to stdout, i3bar by default"`
+	Stop_signal           int       `json:"stop_signal" default:"20" doc:"signal i3bar sends when the bar is hidden, SIGTSTP by default"`
+	Cont_signal           int       `json:"cont_signal" default:"18" doc:"signal i3bar sends when the bar is shown again, SIGCONT by default"`
+}
+
+type LogConfig struct {
+	Level  string `json:"level" allowed:"critical,error,warning,notice,info,debug" doc:"least severe level that is logged"`
+	Output string `json:"output" doc:"stderr, syslog or the path of a file"`
+}
+
+// the block list used when no config file is given
+const defaultConfig = `
+{
+	"version": 1,
+	"blocks": [
+		{
+			"name": "default_time",
+			"module": "time"
+		},
+		{
+			"name": "default_battery",
+			"module": "battery"
+		},
+		{
+			"name":"wireless_network",
+			"module": "net",
+			"interface_name": "wlp3s0",
+			"format": "<span color=\"{{ if .Up }}green{{ else }}red{{end}}\">{{.Interface_name}}</span>: {{range $i, $v := .Addresses}}{{if $i}}, {{end}}{{$v}}{{end}}"
+		},
+		{
+			"name": "default_load",
+			"module": "load"
+		},
+		{
+			"name": "default_memory",
+			"module": "memory"
+		}
+	]
+}`
+
+// loadConfig parses the config file, either a Config object or a bare array
+// of blocks.
+func loadConfig(text []byte) (config *Config, err error) {
+	text = bytes.TrimSpace(text)
+	config = &Config{Version: configVersion}
+
+	if bytes.HasPrefix(text, []byte("[")) {
+		err = json.Unmarshal(text, &config.Blocks)
+		return
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(text))
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(config); err != nil {
+		return
+	}
+
+	if config.Version > configVersion {
+		err = fmt.Errorf("config version %d is not supported, the newest known version is %d", config.Version, configVersion)
+	} else if config.General.Output != "" && !output.Has(config.General.Output) {
+		err = errors.New("unknown output: " + config.General.Output)
+	} else if config.General.Stop_signal < 0 || config.General.Stop_signal > maxSignal {
+		err = fmt.Errorf("stop_signal must be a signal number between 1 and %d", maxSignal)
+	} else if config.General.Cont_signal < 0 || config.General.Cont_signal > maxSignal {
+		err = fmt.Errorf("cont_signal must be a signal number between 1 and %d", maxSignal)
+	}
+	return
+}
+
+// readConfig loads the config file along with its includes, or the default
+// config if fileName is empty. The format is taken from the -format flag or
+// the file extension.
+func readConfig(fileName string) (config *Config, err error) {
+	var value interface{}
+	if fileName == "" {
+		value, err = decodeConfig([]byte(defaultConfig), formatJSON)
+	} else {
+		value, err = readConfigFile(fileName, *configFormatFlag, nil)
+	}
+	if err != nil {
+		return
+	}
+
+	text, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	return loadConfig(text)
+}
+
+// interval returns the configured interval of the bar.
+func (g General) interval(fallback time.Duration) (time.Duration, error) {
+	return parseDuration(map[string]interface{}{"interval": g.Interval}, "interval", fallback)
+}
+
+// the highest signal number on Linux
+const maxSignal = 64
+
+// SIGRTMIN as seen by programs using glibc, the first two realtime signals
+// are reserved by it
+const sigrtmin = 34
+
+// the highest N of SIGRTMIN+N
+const maxRealtimeSignal = maxSignal - sigrtmin
+
+// signals returns the signals i3bar should send to pause and resume the
+// bar.
+func (g General) signals() (stop syscall.Signal, cont syscall.Signal) {
+	stop, cont = syscall.SIGTSTP, syscall.SIGCONT
+	if g.Stop_signal != 0 {
+		stop = syscall.Signal(g.Stop_signal)
+	}
+	if g.Cont_signal != 0 {
+		cont = syscall.Signal(g.Cont_signal)
+	}
+	return
+}
+
+// palette returns the colors of thresholds, unset ones keep their default.
+func (g General) palette() (p modules.Palette) {
+	p = modules.DefaultPalette
+	if g.Good_color != "" {
+		p.Good = g.Good_color
+	}
+	if g.Degraded_color != "" {
+		p.Degraded = g.Degraded_color
+	}
+	if g.Bad_color != "" {
+		p.Bad = g.Bad_color
+	}
+	return
+}
+
+// blockDefaults returns the settings every block starts with.
+func (g General) blockDefaults() (defaults map[string]interface{}) {
+	defaults = make(map[string]interface{})
+	values := map[string]string{
+		"color":       g.Color,
+		"background":  g.Background,
+		"markup":      g.Markup,
+		"error_color": g.Error_color,
+		"stale_color": g.Stale_color,
+	}
+	for key, value := range values {
+		if value != "" {
+			defaults[key] = value
+		}
+	}
+	if g.Separator != nil {
+		defaults["separator"] = *g.Separator
+	}
+	if g.Separator_block_width != nil {
+		defaults["separator_block_width"] = float64(*g.Separator_block_width)
+	}
+	return
+}
+
+// blockConfig merges the general settings and the defaults of the module
+// into the config of a block. The settings of the block take precedence.
+func (c *Config) blockConfig(block map[string]interface{}) (merged map[string]interface{}) {
+	merged = c.General.blockDefaults()
+	if module, ok := block["module"].(string); ok {
+		for key, value := range c.Defaults[module] {
+			merged[key] = value
+		}
+	}
+	for key, value := range block {
+		merged[key] = value
+	}
+	return
+}
+
+// configuredInstance is a module instance together with the block fields its
+// config entry overrides.
+type configuredInstance struct {
+	instance modules.ModuleInstance
+	// the merged config of the block
+	config    map[string]interface{}
+	overrides map[string]interface{}
+	errors    errorSettings
+	// nil if the block has none
+	thresholds *thresholds
+	timeout    time.Duration
+	interval   time.Duration
+	// refresh on SIGRTMIN+signal, 0 for none
+	signal int
+	// the entry of variants applied on top of config, if any
+	variant string
+}
+
+// longest time an instance may take to render unless configured otherwise
+const defaultTimeout = 10 * time.Second
+
+// parseDuration reads an optional duration like "3s" from the config.
+func parseDuration(config map[string]interface{}, key string, fallback time.Duration) (d time.Duration, err error) {
+	v, ok := config[key]
+	if !ok || v == "" {
+		return fallback, nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return 0, &modules.FieldError{Key: key, Err: errors.New(`must be a duration string like "3s"`)}
+	}
+	if d, err = time.ParseDuration(s); err != nil {
+		err = &modules.FieldError{Key: key, Err: err}
+	} else if d <= 0 {
+		err = &modules.FieldError{Key: key, Err: errors.New("must be positive")}
+	}
+	return
+}
+
+// parseModuleConfig creates the instance of a block that passed
+// validateConfig.
+func parseModuleConfig(moduleConfig map[string]interface{}, mods map[string]modules.Module) (instance modules.ModuleInstance, err error) {
+	name, ok := moduleConfig["name"].(string)
+	if !ok {
+		err = errors.New("failed to read name of module")
+		return
+	}
+
+	modname, ok := moduleConfig["module"].(string)
+	if !ok {
+		err = errors.New("failed to read module of " + name)
+		return
+	}
+
+	log.Debug("module:" + string(modname))
+
+	mod, ok := mods[modname]
+	if !ok {
+		err = errors.New("couldn't find module: " + modname)
+		return
+	}
+	return mod.Configure(name, moduleConfig)
+}
+
+// configureBlock creates the instance of a block along with the settings
+// the core handles for it.
+func configureBlock(element map[string]interface{}, mods map[string]modules.Module) (c configuredInstance, err error) {
+	if c.instance, err = parseModuleConfig(element, mods); err != nil {
+		return
+	}
+	log.Debug("Created instance:", c.instance.String())
+
+	c.config = element
+	c.overrides = modules.BlockOverrides(element)
+	if err = new(modules.Block).Apply(c.overrides); err != nil {
+		err = errors.New("invalid block settings: " + err.Error())
+		return
+	}
+	if c.errors, err = parseErrorSettings(element); err != nil {
+		return
+	}
+	if c.thresholds, err = parseThresholds(element); err != nil {
+		return
+	}
+	if c.timeout, err = parseDuration(element, "timeout", defaultTimeout); err != nil {
+		return
+	}
+	if c.interval, err = parseDuration(element, "interval", 0); err != nil {
+		return
+	}
+	c.signal, _ = modules.ToInt(element["signal"])
+	return
+}
+
+func reverseArray(a []configuredInstance) {
+	for i := len(a)/2 - 1; i >= 0; i-- {
+		opp := len(a) - 1 - i
+		a[i], a[opp] = a[opp], a[i]
+	}
+}
+
+// parseConfig creates the instances of all valid blocks. Problems found
+// while validating the config are logged. err tells about the first valid
+// block whose instance couldn't be created nonetheless, it is skipped.
+func parseConfig(config *Config, mods map[string]modules.Module) (instances []configuredInstance, err error) {
+	invalid := make(map[int]bool)
+	for _, p := range validateConfig(config, mods) {
+		if p.warning {
+			log.Warning(p.String())
+		} else {
+			log.Error(p.String())
+			if p.index >= 0 {
+				invalid[p.index] = true
+			}
+		}
+	}
+
+	for index, block := range config.Blocks {
+		if invalid[index] {
+			continue
+		}
+		element := config.blockConfig(block)
+		c, e := configureBlock(element, mods)
+		if e != nil {
+			log.Error(fmt.Sprintf("Failed to create blocks[%d]: %s", index, e.Error()))
+			if err == nil {
+				err = fmt.Errorf("blocks[%d]: %s", index, e.Error())
+			}
+			continue
+		}
+		instances = append(instances, c)
+	}
+	// since we can't insert at the back we've to reverse the order
+	reverseArray(instances)
+	return
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	modules "github.com/andir/go3status/modules"
+)
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		blocks int
+		// part of the error message, if any
+		err string
+	}{
+		{name: "bare list", text: ` [{"name": "a", "module": "time"}, {"name": "b", "module": "time"}]`, blocks: 2},
+		{name: "object", text: `{"version": 1, "general": {"interval": "1s"}, "blocks": [{"name": "a", "module": "time"}]}`, blocks: 1},
+		{name: "without version", text: `{"blocks": []}`},
+		{name: "newer version", text: `{"version": 2, "blocks": []}`, err: "version 2 is not supported"},
+		{name: "unknown key", text: `{"version": 1, "block": []}`, err: `unknown field "block"`},
+		{name: "unknown general key", text: `{"general": {"colour": "#FFFFFF"}}`, err: `unknown field "colour"`},
+		{name: "unknown output", text: `{"general": {"output": "vt100"}}`, err: "unknown output: vt100"},
+		{name: "stop_signal", text: `{"general": {"stop_signal": 65}}`, err: "stop_signal must be"},
+		{name: "cont_signal", text: `{"general": {"cont_signal": -1}}`, err: "cont_signal must be"},
+		{name: "not a list", text: `{"blocks": {}}`, err: "cannot unmarshal"},
+	}
+	for _, test := range tests {
+		config, err := loadConfig([]byte(test.text))
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: got error %v, want one containing %q", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if config.Version != configVersion {
+			t.Errorf("%s: version %d", test.name, config.Version)
+		}
+		if len(config.Blocks) != test.blocks {
+			t.Errorf("%s: %d blocks, want %d", test.name, len(config.Blocks), test.blocks)
+		}
+	}
+}
+
+func TestBlockConfig(t *testing.T) {
+	off := false
+	width := 5
+	config := &Config{
+		General: General{Color: "#111111", Markup: "pango", Separator: &off, Separator_block_width: &width},
+		Defaults: map[string]map[string]interface{}{
+			"time": {"color": "#222222", "format": "15:04"},
+			"load": {"color": "#333333"},
+		},
+	}
+
+	tests := []struct {
+		block map[string]interface{}
+		want  map[string]interface{}
+	}{
+		{
+			block: map[string]interface{}{"name": "a", "module": "time"},
+			want: map[string]interface{}{"name": "a", "module": "time", "color": "#222222", "format": "15:04",
+				"markup": "pango", "separator": false, "separator_block_width": 5.0},
+		},
+		{
+			block: map[string]interface{}{"name": "b", "module": "time", "color": "#444444", "markup": "none"},
+			want: map[string]interface{}{"name": "b", "module": "time", "color": "#444444", "format": "15:04",
+				"markup": "none", "separator": false, "separator_block_width": 5.0},
+		},
+		{
+			block: map[string]interface{}{"name": "c", "module": "memory"},
+			want: map[string]interface{}{"name": "c", "module": "memory", "color": "#111111",
+				"markup": "pango", "separator": false, "separator_block_width": 5.0},
+		},
+	}
+	for _, test := range tests {
+		if got := config.blockConfig(test.block); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("blockConfig(%v) = %v, want %v", test.block, got, test.want)
+		}
+	}
+	if _, ok := config.Defaults["time"]["name"]; ok {
+		t.Error("blockConfig changed the defaults")
+	}
+}
+
+func TestGeneral(t *testing.T) {
+	var g General
+	if got, _ := g.interval(2 * time.Second); got != 2*time.Second {
+		t.Errorf("default interval %s", got)
+	}
+	if stop, cont := g.signals(); stop != syscall.SIGTSTP || cont != syscall.SIGCONT {
+		t.Errorf("default signals %s, %s", stop, cont)
+	}
+	if got := g.palette(); got != modules.DefaultPalette {
+		t.Errorf("default palette %+v", got)
+	}
+	if got := g.blockDefaults(); len(got) != 0 {
+		t.Errorf("default block settings %v", got)
+	}
+
+	g = General{Interval: "500ms", Stop_signal: 10, Cont_signal: 12, Bad_color: "#990000"}
+	if got, _ := g.interval(2 * time.Second); got != 500*time.Millisecond {
+		t.Errorf("interval %s", got)
+	}
+	if stop, cont := g.signals(); stop != syscall.SIGUSR1 || cont != syscall.SIGUSR2 {
+		t.Errorf("signals %s, %s", stop, cont)
+	}
+	want := modules.DefaultPalette
+	want.Bad = "#990000"
+	if got := g.palette(); got != want {
+		t.Errorf("palette %+v", got)
+	}
+	if _, err := (General{Interval: "-1s"}).interval(time.Second); err == nil {
+		t.Error("negative interval accepted")
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	modules "github.com/andir/go3status/modules"
+)
+
+// The control socket speaks JSON, one request per line answered by one
+// response per line:
+//
+//	{"command": "hide", "block": "wifi"}
+//	{"ok": true}
+
+type ctlRequest struct {
+	Command string `json:"command"`
+	Block   string `json:"block,omitempty"`
+	Variant string `json:"variant,omitempty"`
+	Text    string `json:"text,omitempty"`
+	// how long text is shown, e.g. "5s"
+	Duration string `json:"duration,omitempty"`
+}
+
+type ctlResponse struct {
+	Ok     bool         `json:"ok"`
+	Error  string       `json:"error,omitempty"`
+	Blocks []blockState `json:"blocks,omitempty"`
+}
+
+// ctlCall is a request waiting to be handled by the main loop.
+type ctlCall struct {
+	request ctlRequest
+	reply   chan ctlResponse
+}
+
+// how long set_text shows the text unless a duration is given
+const defaultTextDuration = 5 * time.Second
+
+// socketPath returns the path of the control socket.
+func socketPath() string {
+	if *socket != "" {
+		return *socket
+	}
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		return filepath.Join(os.TempDir(), "go3status-"+strconv.Itoa(os.Getuid())+".sock")
+	}
+	return filepath.Join(dir, "go3status.sock")
+}
+
+// listenCtl opens the control socket. A socket left behind by a crashed
+// instance is replaced, one that is still served is not.
+func listenCtl(path string) (listener *net.UnixListener, err error) {
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return nil, errors.New(path + " is in use by another instance")
+	}
+	os.Remove(path)
+
+	// restricting the socket after it was created would let other users
+	// connect in between
+	mask := syscall.Umask(0077)
+	listener, err = net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	syscall.Umask(mask)
+	return
+}
+
+// serveCtl accepts connections on the control socket and passes their
+// requests to calls until the listener is closed.
+func serveCtl(listener *net.UnixListener, calls chan<- ctlCall) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			scanner := bufio.NewScanner(conn)
+			encoder := json.NewEncoder(conn)
+			for scanner.Scan() {
+				var response ctlResponse
+				call := ctlCall{reply: make(chan ctlResponse, 1)}
+				if err := json.Unmarshal(scanner.Bytes(), &call.request); err != nil {
+					response.Error = "invalid request: " + err.Error()
+				} else {
+					calls <- call
+					response = <-call.reply
+				}
+				if encoder.Encode(response) != nil {
+					return
+				}
+			}
+		}()
+	}
+}
+
+// handleCtl runs a request on the main loop.
+func handleCtl(request ctlRequest, scheduler *Scheduler, reload func() error, mods map[string]modules.Module) (response ctlResponse) {
+	log.Debug("Control command " + request.Command + " " + request.Block)
+
+	var err error
+	switch request.Command {
+	case "refresh":
+		if request.Block == "" {
+			scheduler.RefreshAll()
+		} else {
+			err = scheduler.Refresh(request.Block)
+		}
+	case "hide":
+		err = scheduler.SetHidden(request.Block, true)
+	case "show":
+		err = scheduler.SetHidden(request.Block, false)
+	case "variant":
+		err = scheduler.SetVariant(request.Block, request.Variant, mods)
+	case "set_text":
+		duration := defaultTextDuration
+		if request.Duration != "" {
+			duration, err = time.ParseDuration(request.Duration)
+		}
+		if err == nil {
+			err = scheduler.SetText(request.Block, request.Text, duration)
+		}
+	case "dump":
+		response.Blocks = scheduler.Dump()
+	case "reload":
+		err = reload()
+	default:
+		err = errors.New("unknown command: " + request.Command)
+	}
+
+	if err != nil {
+		response.Error = err.Error()
+	} else {
+		response.Ok = true
+	}
+	return
+}
+
+// ctlUsage lists the commands of the ctl subcommand.
+const ctlUsage = `Commands:
+  refresh [block]                  render the block or all blocks right away
+  hide <block>                     remove the block from the bar
+  show <block>                     show a hidden block again
+  variant <block> [variant]        switch to a variant of the block config
+  set-text <block> <text> [for]    show text instead, for 5s by default
+  dump                             print the state of all blocks
+  reload                           reload the config file
+`
+
+// parseCtlArgs turns the arguments of the ctl subcommand into a request.
+func parseCtlArgs(args []string) (request ctlRequest, err error) {
+	if len(args) == 0 {
+		err = errors.New("missing command")
+		return
+	}
+	request.Command = args[0]
+	args = args[1:]
+
+	// the number of required and optional arguments of every command
+	arity := map[string][2]int{
+		"refresh":  {0, 1},
+		"hide":     {1, 0},
+		"show":     {1, 0},
+		"variant":  {1, 1},
+		"set-text": {2, 1},
+		"dump":     {0, 0},
+		"reload":   {0, 0},
+	}
+	n, ok := arity[request.Command]
+	if !ok {
+		err = errors.New("unknown command: " + request.Command)
+		return
+	} else if len(args) < n[0] || len(args) > n[0]+n[1] {
+		err = errors.New("wrong number of arguments for " + request.Command)
+		return
+	}
+
+	if len(args) > 0 {
+		request.Block = args[0]
+	}
+	switch request.Command {
+	case "variant":
+		if len(args) > 1 {
+			request.Variant = args[1]
+		}
+	case "set-text":
+		request.Command = "set_text"
+		request.Text = args[1]
+		if len(args) > 2 {
+			request.Duration = args[2]
+		}
+	}
+	return
+}
+
+// runCtl sends a command to a running instance and prints the response. It
+// returns the exit code of the ctl subcommand.
+func runCtl(args []string) int {
+	request, err := parseCtlArgs(args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		fmt.Fprint(os.Stderr, ctlUsage)
+		return 2
+	}
+
+	conn, err := net.Dial("unix", socketPath())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	defer conn.Close()
+
+	if err := json.NewEncoder(conn).Encode(request); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	var response ctlResponse
+	if err := json.NewDecoder(conn).Decode(&response); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	if !response.Ok {
+		fmt.Fprintln(os.Stderr, response.Error)
+		return 1
+	}
+	if request.Command == "dump" {
+		text, _ := json.MarshalIndent(response.Blocks, "", "  ")
+		fmt.Println(string(text))
+	}
+	return 0
+}
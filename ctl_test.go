@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseCtlArgs(t *testing.T) {
+	tests := []struct {
+		args []string
+		want ctlRequest
+		err  string
+	}{
+		{args: []string{"refresh"}, want: ctlRequest{Command: "refresh"}},
+		{args: []string{"refresh", "a"}, want: ctlRequest{Command: "refresh", Block: "a"}},
+		{args: []string{"hide", "a"}, want: ctlRequest{Command: "hide", Block: "a"}},
+		{args: []string{"show", "a"}, want: ctlRequest{Command: "show", Block: "a"}},
+		{args: []string{"variant", "a"}, want: ctlRequest{Command: "variant", Block: "a"}},
+		{args: []string{"variant", "a", "short"}, want: ctlRequest{Command: "variant", Block: "a", Variant: "short"}},
+		{args: []string{"set-text", "a", "hi there"}, want: ctlRequest{Command: "set_text", Block: "a", Text: "hi there"}},
+		{args: []string{"set-text", "a", "hi", "3s"}, want: ctlRequest{Command: "set_text", Block: "a", Text: "hi", Duration: "3s"}},
+		{args: []string{"dump"}, want: ctlRequest{Command: "dump"}},
+		{args: []string{"reload"}, want: ctlRequest{Command: "reload"}},
+		{args: nil, err: "missing command"},
+		{args: []string{"restart"}, err: "unknown command: restart"},
+		{args: []string{"set_text", "a", "hi"}, err: "unknown command: set_text"},
+		{args: []string{"refresh", "a", "b"}, err: "wrong number of arguments for refresh"},
+		{args: []string{"hide"}, err: "wrong number of arguments for hide"},
+		{args: []string{"show", "a", "b"}, err: "wrong number of arguments for show"},
+		{args: []string{"variant"}, err: "wrong number of arguments for variant"},
+		{args: []string{"variant", "a", "b", "c"}, err: "wrong number of arguments for variant"},
+		{args: []string{"set-text", "a"}, err: "wrong number of arguments for set-text"},
+		{args: []string{"set-text", "a", "hi", "3s", "x"}, err: "wrong number of arguments for set-text"},
+		{args: []string{"dump", "a"}, err: "wrong number of arguments for dump"},
+		{args: []string{"reload", "now"}, err: "wrong number of arguments for reload"},
+	}
+	for _, test := range tests {
+		got, err := parseCtlArgs(test.args)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("parseCtlArgs(%q) = %v, want the error %q", test.args, err, test.err)
+			}
+		} else if err != nil {
+			t.Errorf("parseCtlArgs(%q) failed: %s", test.args, err)
+		} else if got != test.want {
+			t.Errorf("parseCtlArgs(%q) = %+v, want %+v", test.args, got, test.want)
+		}
+	}
+}
+
+func TestHandleCtl(t *testing.T) {
+	a := &fakeInstance{name: "a"}
+	config := map[string]interface{}{
+		"name":     "a",
+		"module":   "time",
+		"variants": map[string]interface{}{"short": map[string]interface{}{"format": "15:04"}},
+	}
+	s := startScheduler(t, fakeConfigured(a, config))
+	eventually(t, "the first render", func() bool { return s.Store().Complete() })
+
+	reloadErr := errors.New("config is broken")
+	reload := func() error { return reloadErr }
+	mods := registeredModules()
+
+	tests := []struct {
+		request ctlRequest
+		err     string
+	}{
+		{ctlRequest{Command: "refresh"}, ""},
+		{ctlRequest{Command: "refresh", Block: "a"}, ""},
+		{ctlRequest{Command: "refresh", Block: "b"}, "unknown block: b"},
+		{ctlRequest{Command: "hide", Block: "b"}, "unknown block: b"},
+		{ctlRequest{Command: "show", Block: "b"}, "unknown block: b"},
+		{ctlRequest{Command: "variant", Block: "b"}, "unknown block: b"},
+		{ctlRequest{Command: "variant", Block: "a", Variant: "long"}, "a has no variant long"},
+		{ctlRequest{Command: "set_text", Block: "b", Text: "hi"}, "unknown block: b"},
+		{ctlRequest{Command: "set_text", Block: "a", Text: "hi", Duration: "soon"}, `time: invalid duration "soon"`},
+		{ctlRequest{Command: "reload"}, "config is broken"},
+		{ctlRequest{Command: "restart"}, "unknown command: restart"},
+		{ctlRequest{}, "unknown command: "},
+		{ctlRequest{Command: "hide", Block: "a"}, ""},
+	}
+	for _, test := range tests {
+		response := handleCtl(test.request, s, reload, mods)
+		if response.Ok != (test.err == "") || response.Error != test.err {
+			t.Errorf("handleCtl(%+v) = %+v, want the error %q", test.request, response, test.err)
+		}
+	}
+
+	response := handleCtl(ctlRequest{Command: "dump"}, s, reload, mods)
+	if len(response.Blocks) != 1 {
+		t.Fatalf("dump = %+v", response)
+	}
+	got := response.Blocks[0]
+	got.Block = nil
+	if want := (blockState{Name: "a", Module: "time", Hidden: true}); !response.Ok || !reflect.DeepEqual(got, want) {
+		t.Errorf("dump = %+v, want %+v", got, want)
+	}
+
+	handleCtl(ctlRequest{Command: "show", Block: "a"}, s, reload, mods)
+	handleCtl(ctlRequest{Command: "set_text", Block: "a", Text: "hi", Duration: "1h"}, s, reload, mods)
+	if got := texts(s.Store().Blocks()); got != "hi" {
+		t.Errorf("blocks = %s, want hi", got)
+	}
+}
+
+func TestListenCtl(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ctl.sock")
+	// a socket left behind by a crashed instance is replaced
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := listenCtl(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm&0077 != 0 {
+		t.Errorf("socket is accessible by others: %s", info.Mode())
+	}
+
+	calls := make(chan ctlCall)
+	go serveCtl(listener, calls)
+	go func() {
+		call := <-calls
+		call.reply <- ctlResponse{Ok: call.request.Command == "dump"}
+	}()
+	if _, err := listenCtl(path); err == nil {
+		t.Error("listening on a socket that is in use")
+	}
+
+	*socket = path
+	defer func() { *socket = "" }()
+	done := make(chan int)
+	go func() { done <- runCtl([]string{"dump"}) }()
+	select {
+	case code := <-done:
+		if code != 0 {
+			t.Errorf("runCtl = %d", code)
+		}
+	case <-time.After(time.Second):
+		t.Error("runCtl hangs")
+	}
+}
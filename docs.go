@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	modules "github.com/andir/go3status/modules"
+)
+
+// writeSchemaTable writes the keys of a schema as a Markdown table.
+func writeSchemaTable(w io.Writer, schema modules.Schema) {
+	fmt.Fprintln(w, "| Key | Type | Default | Description |")
+	fmt.Fprintln(w, "| --- | ---- | ------- | ----------- |")
+	for _, key := range sortedKeys(schema) {
+		field := schema[key]
+		def := ""
+		if field.Default != nil {
+			def = fmt.Sprintf("`%v`", field.Default)
+		} else if field.Required {
+			def = "required"
+		}
+		doc := field.Doc
+		if len(field.Allowed) > 0 {
+			doc += " (one of " + strings.Join(field.Allowed, ", ") + ")"
+		}
+		doc = strings.Replace(doc, "|", "\\|", -1)
+		def = strings.Replace(def, "|", "\\|", -1)
+		fmt.Fprintf(w, "| `%s` | %s | %s | %s |\n", key, field.Type, def, doc)
+	}
+	fmt.Fprintln(w)
+}
+
+// writeDocs writes the reference of all config keys in Markdown.
+func writeDocs(w io.Writer, mods map[string]modules.Module) {
+	fmt.Fprintln(w, "# Configuration reference")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "## general")
+	fmt.Fprintln(w)
+	writeSchemaTable(w, modules.SchemaOf(&General{}))
+	fmt.Fprintln(w, "### general.log")
+	fmt.Fprintln(w)
+	writeSchemaTable(w, modules.SchemaOf(&LogConfig{}))
+	fmt.Fprintln(w, "## Keys of every block")
+	fmt.Fprintln(w)
+	writeSchemaTable(w, coreSchema)
+	fmt.Fprintln(w, "### thresholds")
+	fmt.Fprintln(w)
+	writeSchemaTable(w, thresholdsSchema)
+	fmt.Fprintln(w, "## Block fields")
+	fmt.Fprintln(w)
+	writeSchemaTable(w, modules.BlockSchema)
+	for _, name := range sortedKeys(mods) {
+		fmt.Fprintln(w, "## Module "+name)
+		fmt.Fprintln(w)
+		writeSchemaTable(w, mods[name].Schema())
+		if metrics := mods[name].Metrics; len(metrics) > 0 {
+			fmt.Fprintln(w, "Metrics for thresholds: `"+strings.Join(metrics, "`, `")+"`")
+			fmt.Fprintln(w)
+		}
+	}
+}
+
+// jsonSchemaTypes maps field types to JSON Schema types.
+var jsonSchemaTypes = map[modules.FieldType]interface{}{
+	modules.String:      "string",
+	modules.Int:         "integer",
+	modules.Number:      "number",
+	modules.Bool:        "boolean",
+	modules.Duration:    "string",
+	modules.IntOrString: []string{"integer", "string"},
+	modules.List:        "array",
+	modules.Object:      "object",
+}
+
+type jsonObject map[string]interface{}
+
+// jsonSchemaProperties converts the keys of a schema to JSON Schema
+// properties, adding the names of required keys to required.
+func jsonSchemaProperties(schema modules.Schema, properties jsonObject, required *[]string) {
+	for _, key := range sortedKeys(schema) {
+		field := schema[key]
+		property := jsonObject{}
+		if t, ok := jsonSchemaTypes[field.Type]; ok {
+			property["type"] = t
+		}
+		if field.Doc != "" {
+			property["description"] = field.Doc
+		}
+		if field.Default != nil {
+			property["default"] = field.Default
+		}
+		if len(field.Allowed) > 0 {
+			property["enum"] = field.Allowed
+		}
+		if field.Type == modules.Duration {
+			property["pattern"] = `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+		}
+		properties[key] = property
+		if field.Required && required != nil {
+			*required = append(*required, key)
+		}
+	}
+}
+
+func objectSchema(schemas ...modules.Schema) jsonObject {
+	properties := jsonObject{}
+	required := []string{}
+	for _, schema := range schemas {
+		jsonSchemaProperties(schema, properties, &required)
+	}
+	object := jsonObject{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		object["required"] = required
+	}
+	return object
+}
+
+// jsonSchema builds a JSON Schema of the config file for editors.
+func jsonSchema(mods map[string]modules.Module) jsonObject {
+	general := objectSchema(modules.SchemaOf(&General{}))
+	general["additionalProperties"] = false
+	general["properties"].(jsonObject)["log"] = objectSchema(modules.SchemaOf(&LogConfig{}))
+
+	block := objectSchema(coreSchema, modules.BlockSchema)
+	thresholds := objectSchema(thresholdsSchema)
+	thresholds["additionalProperties"] = false
+	block["properties"].(jsonObject)["thresholds"] = thresholds
+	names := []string{}
+	conditions := []interface{}{}
+	defaults := jsonObject{}
+	for _, name := range sortedKeys(mods) {
+		names = append(names, name)
+		module := objectSchema(mods[name].Schema())
+		conditions = append(conditions, jsonObject{
+			"if": jsonObject{
+				"properties": jsonObject{"module": jsonObject{"const": name}},
+				"required":   []string{"module"},
+			},
+			"then": module,
+		})
+		// defaults of a module don't need its required keys
+		moduleDefaults := objectSchema(mods[name].Schema())
+		delete(moduleDefaults, "required")
+		defaults[name] = moduleDefaults
+	}
+	block["properties"].(jsonObject)["module"].(jsonObject)["enum"] = names
+	block["allOf"] = conditions
+
+	include := jsonObject{
+		"type": "object",
+		"properties": jsonObject{
+			"include": jsonObject{"type": "string", "description": "file whose blocks are inserted here, may be a glob"},
+		},
+		"required":             []string{"include"},
+		"additionalProperties": false,
+	}
+	blocks := jsonObject{"type": "array", "items": jsonObject{"oneOf": []interface{}{include, block}}}
+	return jsonObject{
+		"$schema": "http://json-schema.org/draft-07/schema#",
+		"title":   "go3status config",
+		"oneOf": []interface{}{
+			jsonObject{
+				"type": "object",
+				"properties": jsonObject{
+					"version":  jsonObject{"type": "integer", "maximum": configVersion},
+					"general":  general,
+					"defaults": jsonObject{"type": "object", "properties": defaults},
+					"blocks":   blocks,
+				},
+				"additionalProperties": false,
+			},
+			blocks,
+		},
+	}
+}
+
+// writeJSONSchema writes the JSON Schema of the config file.
+func writeJSONSchema(w io.Writer, mods map[string]modules.Module) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(jsonSchema(mods))
+}
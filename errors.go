@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	modules "github.com/andir/go3status/modules"
+)
+
+// errorSettings control the block that is shown in the slot of an instance
+// whose Render failed or timed out.
+type errorSettings struct {
+	color string
+	// the block turns urgent after this many failures in a row, 0 never
+	urgent_after int
+	// color of the last good block while the instance times out
+	stale_color string
+}
+
+var defaultErrorSettings = errorSettings{
+	color:        "#FF0000",
+	urgent_after: 3,
+	stale_color:  "#888888",
+}
+
+// longest error message shown in the bar
+const maxErrorLength = 60
+
+func parseErrorSettings(config map[string]interface{}) (settings errorSettings, err error) {
+	settings = defaultErrorSettings
+
+	if v, ok := config["error_color"]; ok {
+		if settings.color, ok = v.(string); !ok {
+			err = &modules.FieldError{Key: "error_color", Err: errors.New("must be a string")}
+			return
+		}
+	}
+
+	if v, ok := config["stale_color"]; ok {
+		if settings.stale_color, ok = v.(string); !ok {
+			err = &modules.FieldError{Key: "stale_color", Err: errors.New("must be a string")}
+			return
+		}
+	}
+
+	if v, ok := config["error_urgent_after"]; ok {
+		if n, ok := modules.ToInt(v); ok && n >= 0 {
+			settings.urgent_after = n
+		} else {
+			err = &modules.FieldError{Key: "error_urgent_after", Err: errors.New("must be a non-negative number")}
+			return
+		}
+	}
+	return
+}
+
+// errorBlock renders the error of the named instance after the given number
+// of consecutive failures.
+func (s errorSettings) errorBlock(name string, err error, failures int) *modules.Block {
+	reason := []rune(err.Error())
+	if len(reason) > maxErrorLength {
+		reason = append(reason[:maxErrorLength-1], '…')
+	}
+
+	return &modules.Block{
+		Name:       name,
+		Full_text:  fmt.Sprintf("%s: %s", name, string(reason)),
+		Short_text: name + ": error",
+		Color:      s.color,
+		Urgent:     s.urgent_after > 0 && failures >= s.urgent_after,
+	}
+}
+
+// staleBlock marks the last good block of an instance as outdated.
+func (s errorSettings) staleBlock(last *modules.Block) *modules.Block {
+	block := *last
+	block.Color = s.stale_color
+	return &block
+}
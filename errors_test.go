@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseErrorSettings(t *testing.T) {
+	tests := []struct {
+		config map[string]interface{}
+		want   errorSettings
+		// the key of the expected error, if any
+		err string
+	}{
+		{config: map[string]interface{}{}, want: defaultErrorSettings},
+		{
+			config: map[string]interface{}{"error_color": "#FF00FF", "stale_color": "#111111", "error_urgent_after": 5.0},
+			want:   errorSettings{color: "#FF00FF", urgent_after: 5, stale_color: "#111111"},
+		},
+		{
+			config: map[string]interface{}{"error_urgent_after": 0},
+			want:   errorSettings{color: "#FF0000", urgent_after: 0, stale_color: "#888888"},
+		},
+		{config: map[string]interface{}{"error_color": 1.0}, err: "error_color"},
+		{config: map[string]interface{}{"stale_color": true}, err: "stale_color"},
+		{config: map[string]interface{}{"error_urgent_after": -1.0}, err: "error_urgent_after"},
+		{config: map[string]interface{}{"error_urgent_after": 1.5}, err: "error_urgent_after"},
+		{config: map[string]interface{}{"error_urgent_after": "3"}, err: "error_urgent_after"},
+	}
+	for _, test := range tests {
+		got, err := parseErrorSettings(test.config)
+		if test.err != "" {
+			if err == nil || !strings.HasPrefix(err.Error(), test.err+":") {
+				t.Errorf("parseErrorSettings(%v) = %v, want an error for %s", test.config, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseErrorSettings(%v) failed: %s", test.config, err)
+		} else if got != test.want {
+			t.Errorf("parseErrorSettings(%v) = %+v, want %+v", test.config, got, test.want)
+		}
+	}
+}
+
+func TestErrorBlock(t *testing.T) {
+	settings := errorSettings{color: "#FF0000", urgent_after: 2}
+	long := strings.Repeat("x", maxErrorLength+10)
+
+	tests := []struct {
+		err      string
+		failures int
+		text     string
+		urgent   bool
+	}{
+		{"broken", 1, "a: broken", false},
+		{"broken", 2, "a: broken", true},
+		{"broken", 3, "a: broken", true},
+		{long, 1, "a: " + long[:maxErrorLength-1] + "…", false},
+		{strings.Repeat("ä", maxErrorLength), 1, "a: " + strings.Repeat("ä", maxErrorLength), false},
+	}
+	for _, test := range tests {
+		block := settings.errorBlock("a", errors.New(test.err), test.failures)
+		if block.Full_text != test.text || block.Urgent != test.urgent || block.Color != "#FF0000" || block.Short_text != "a: error" {
+			t.Errorf("errorBlock(%q, %d) = %+v", test.err, test.failures, block)
+		}
+	}
+
+	if block := (errorSettings{}).errorBlock("a", errors.New("broken"), 100); block.Urgent {
+		t.Error("urgent although urgent_after is 0")
+	}
+}
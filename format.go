@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// the supported config file formats
+const (
+	formatJSON = "json"
+	formatYAML = "yaml"
+	formatTOML = "toml"
+)
+
+// configFormat returns the format of the config file, either the given one
+// or the one matching the extension of the file. JSON is the fallback.
+func configFormat(fileName string, format string) (string, error) {
+	switch strings.ToLower(format) {
+	case "":
+	case formatJSON, formatYAML, formatTOML:
+		return strings.ToLower(format), nil
+	case "yml":
+		return formatYAML, nil
+	default:
+		return "", errors.New("unknown config format: " + format)
+	}
+
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".yaml", ".yml":
+		return formatYAML, nil
+	case ".toml":
+		return formatTOML, nil
+	}
+	return formatJSON, nil
+}
+
+// decodeConfig decodes a config file of the given format into plain maps
+// and lists, so every format ends up in the same Config.
+func decodeConfig(text []byte, format string) (value interface{}, err error) {
+	switch format {
+	case formatJSON:
+		decoder := json.NewDecoder(bytes.NewReader(text))
+		decoder.UseNumber()
+		err = decoder.Decode(&value)
+	case formatYAML:
+		err = yaml.Unmarshal(text, &value)
+	case formatTOML:
+		_, err = toml.Decode(string(text), &value)
+	}
+	if err != nil {
+		return
+	}
+	return normalize(value)
+}
+
+// normalize converts the maps of a decoded YAML document to maps with string
+// keys that encoding/json can handle.
+func normalize(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			s, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("keys must be strings, not %v", key)
+			}
+			m[s] = item
+		}
+		return normalize(m)
+	case map[string]interface{}:
+		for key, item := range v {
+			n, err := normalize(item)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = n
+		}
+	case []interface{}:
+		for i, item := range v {
+			n, err := normalize(item)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = n
+		}
+	case []map[string]interface{}:
+		// arrays of tables in TOML
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = item
+		}
+		return normalize(list)
+	}
+	return value, nil
+}
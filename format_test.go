@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestConfigFormat(t *testing.T) {
+	tests := []struct {
+		fileName string
+		format   string
+		want     string
+		err      bool
+	}{
+		{fileName: "config.json", want: formatJSON},
+		{fileName: "config.yaml", want: formatYAML},
+		{fileName: "config.YML", want: formatYAML},
+		{fileName: "config.toml", want: formatTOML},
+		{fileName: "config", want: formatJSON},
+		{fileName: "config.conf", want: formatJSON},
+		{fileName: "config.json", format: "yaml", want: formatYAML},
+		{fileName: "config.json", format: "yml", want: formatYAML},
+		{fileName: "config.yaml", format: "TOML", want: formatTOML},
+		{fileName: "config.yaml", format: "ini", err: true},
+	}
+	for _, test := range tests {
+		got, err := configFormat(test.fileName, test.format)
+		if test.err {
+			if err == nil {
+				t.Errorf("configFormat(%q, %q) = %q, want an error", test.fileName, test.format, got)
+			}
+		} else if err != nil || got != test.want {
+			t.Errorf("configFormat(%q, %q) = %q, %v, want %q", test.fileName, test.format, got, err, test.want)
+		}
+	}
+}
+
+func TestDecodeConfig(t *testing.T) {
+	jsonText := `{
+		"version": 1,
+		"general": {"interval": "1s", "separator": false},
+		"defaults": {"time": {"format": "15:04"}},
+		"blocks": [
+			{"name": "a", "module": "time", "format": "{{ .Now }}", "signal": 3},
+			{"name": "b", "module": "load", "thresholds": {"metric": "load1", "bad": 2.5}}
+		]
+	}`
+	yaml := `
+version: 1
+general:
+  interval: 1s
+  separator: false
+defaults:
+  time:
+    format: "15:04"
+blocks:
+  - name: a
+    module: time
+    format: '{{ .Now }}'
+    signal: 3
+  - name: b
+    module: load
+    thresholds: {metric: load1, bad: 2.5}
+`
+	toml := `
+version = 1
+
+[general]
+interval = "1s"
+separator = false
+
+[defaults.time]
+format = "15:04"
+
+[[blocks]]
+name = "a"
+module = "time"
+format = "{{ .Now }}"
+signal = 3
+
+[[blocks]]
+name = "b"
+module = "load"
+thresholds = { metric = "load1", bad = 2.5 }
+`
+
+	var want *Config
+	for _, test := range []struct{ format, text string }{{formatJSON, jsonText}, {formatYAML, yaml}, {formatTOML, toml}} {
+		value, err := decodeConfig([]byte(test.text), test.format)
+		if err != nil {
+			t.Errorf("%s: %s", test.format, err)
+			continue
+		}
+		// readConfig hands the decoded value to loadConfig as JSON
+		config := mustLoadConfig(t, value)
+		if want == nil {
+			want = config
+			if len(want.Blocks) != 2 || want.General.Interval != "1s" || want.Defaults["time"]["format"] != "15:04" {
+				t.Fatalf("%s: unexpected config %+v", test.format, want)
+			}
+		} else if !reflect.DeepEqual(config, want) {
+			t.Errorf("%s: got %+v, want %+v", test.format, config, want)
+		}
+	}
+
+	for _, test := range []struct{ format, text string }{
+		{formatJSON, `{"blocks": [}`},
+		{formatYAML, "blocks:\n  - name: a\n - name: b"},
+		{formatYAML, "1: a"},
+		{formatTOML, "blocks = ["},
+	} {
+		if _, err := decodeConfig([]byte(test.text), test.format); err == nil {
+			t.Errorf("%s: %q decoded without an error", test.format, test.text)
+		}
+	}
+}
+
+// mustLoadConfig loads a decoded config the way readConfig does.
+func mustLoadConfig(t *testing.T, value interface{}) *Config {
+	t.Helper()
+	text, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := loadConfig(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
@@ -1,9 +1,10 @@
 package main
 
 import (
-	"encoding/json"
+	"flag"
 	"fmt"
-	"io/ioutil"
+	"io"
+	"net/http"
 	"os"
 	"os/signal"
 	"strings"
@@ -12,170 +13,253 @@ import (
 
 	modules "github.com/andir/go3status/modules"
 	go3_battery "github.com/andir/go3status/modules/battery"
+	go3_exec "github.com/andir/go3status/modules/exec"
 	go3_idlerpg "github.com/andir/go3status/modules/idlerpg"
 	go3_load "github.com/andir/go3status/modules/load"
 	go3_memory "github.com/andir/go3status/modules/memory"
 	go3_mpd "github.com/andir/go3status/modules/mpd"
 	go3_net "github.com/andir/go3status/modules/net"
+	go3_push "github.com/andir/go3status/modules/push"
 	go3_time "github.com/andir/go3status/modules/time"
+	"github.com/andir/go3status/output"
 	"github.com/op/go-logging"
 )
 
 var log = logging.MustGetLogger("go3status")
 
-type Element struct {
-	Color                 string `json:"color"`
-	Name                  string `json:"name"`
-	Full_text             string `json:"full_text"`
-	Markup                string `json:"markup"`
-	Seperator_block_width int    `json:"seperator_block_width"`
-}
+// the file or syslog connection the log is written to, if any
+var logOutput io.Closer
 
-func setupLogging() {
+// setupLogging replaces the log backend, the output of the previous one is
+// closed.
+func setupLogging(config LogConfig) error {
 	var format = logging.MustStringFormatter(
 		"%{color}%{time:15:04:05.000} %{shortfunc} ▶ %{level:.4s} %{id:03x}%{color:reset} %{message}",
 	)
-	backend := logging.NewLogBackend(os.Stderr, "", 0)
+	var backend logging.Backend
+	var output io.Closer
+
+	switch config.Output {
+	case "", "stderr":
+		backend = logging.NewLogBackend(os.Stderr, "", 0)
+	case "syslog":
+		if b, err := logging.NewSyslogBackend("go3status"); err == nil {
+			backend = b
+			output = b.Writer
+			format = logging.MustStringFormatter("%{shortfunc} ▶ %{level:.4s} %{message}")
+		} else {
+			return err
+		}
+	default:
+		if f, err := os.OpenFile(config.Output, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644); err == nil {
+			output = f
+			backend = logging.NewLogBackend(f, "", 0)
+			format = logging.MustStringFormatter("%{time:15:04:05.000} %{shortfunc} ▶ %{level:.4s} %{id:03x} %{message}")
+		} else {
+			return err
+		}
+	}
 
-	backendFormatter := logging.NewBackendFormatter(backend, format)
+	backendFormatter := logging.AddModuleLevel(logging.NewBackendFormatter(backend, format))
+	if config.Level != "" {
+		if level, err := logging.LogLevel(config.Level); err == nil {
+			backendFormatter.SetLevel(level, "")
+		} else {
+			if output != nil {
+				output.Close()
+			}
+			return err
+		}
+	}
 
 	logging.SetBackend(backendFormatter)
+	if logOutput != nil {
+		logOutput.Close()
+	}
+	logOutput = output
+	return nil
 }
 
-func parseModuleConfig(moduleConfig map[string]interface{}, mods map[string]modules.Module) (instance modules.ModuleInstance) {
-	var mod modules.Module
-	var ok bool
-	var val interface{}
-	var name string
+const debounceInterval = 50 * time.Millisecond
 
-	val, ok = moduleConfig["name"]
-	if !ok {
-		log.Fatal("Failed to read name of module")
+func mainLoop(interval time.Duration, instances []configuredInstance, general General, backend output.Backend, reloads *reloader, calls <-chan ctlCall) {
+	stop, cont := general.signals()
+	if err := backend.Begin(os.Stdout, output.Header{Stop_signal: int(stop), Cont_signal: int(cont)}); err != nil {
+		log.Error("Failed to write to stdout: " + err.Error())
 		return
 	}
-	name = val.(string)
-
-	val, ok = moduleConfig["module"]
 
-	if !ok {
-		log.Fatal("Failed to parse config")
-		return
+	// SIGSTOP can't be caught, the process is simply stopped then
+	sigs := make(chan os.Signal, 16)
+	signal.Notify(sigs, syscall.SIGHUP, syscall.SIGUSR1, syscall.SIGTERM, syscall.SIGINT)
+	if backend.Pauses() {
+		signal.Notify(sigs, stop, cont)
 	}
-	modname := val.(string)
-
-	log.Debug("module:" + string(modname))
-
-	if mod, ok = mods[modname]; !ok {
-		log.Error("Couldn't find module: " + modname)
-		return
+	for n := 1; n <= maxRealtimeSignal; n++ {
+		signal.Notify(sigs, syscall.Signal(sigrtmin+n))
 	}
-	instance = mod.CreateInstance(name, moduleConfig)
 
-	return
-}
-
-func reverseArray(a []modules.ModuleInstance) {
-	for i := len(a)/2 - 1; i >= 0; i-- {
-		opp := len(a) - 1 - i
-		a[i], a[opp] = a[opp], a[i]
+	// other bars don't write to our stdin, which may well be a terminal
+	clicks := make(chan modules.ClickEvent)
+	if backend.ClickEvents() {
+		go readClickEvents(os.Stdin, clicks)
 	}
-}
 
-func parseConfig(config string, mods map[string]modules.Module) (instances []modules.ModuleInstance) {
-	var list []map[string]interface{}
-
-	if err := json.Unmarshal([]byte(config), &list); err == nil {
-		for _, element := range list {
-			instance := parseModuleConfig(element, mods)
-			if instance != nil {
-				log.Debug("Created instance:", instance.String())
-				instances = append(instances, instance)
-			} else {
-				log.Debug("Failed to parse config for ", element)
+	scheduler := NewScheduler(instances)
+	scheduler.Start()
+	store := scheduler.Store()
+
+	// the ticker only keeps the bar alive, blocks are emitted as soon as
+	// any of them changes
+	ticker := time.NewTicker(interval)
+
+	logConfig := general.Log
+	reload := func() error {
+		config, interval, instances, err := reloads.reload()
+		if err != nil {
+			log.Error("Failed to reload config, keeping the old one: " + err.Error())
+			store.SetNotice(defaultErrorSettings.errorBlock("config", err, 0))
+			return err
+		}
+		// reopening the same log file on every reload is pointless
+		if config.General.Log != logConfig {
+			if err := setupLogging(config.General.Log); err != nil {
+				log.Error("Failed to set up logging: " + err.Error())
 			}
+			logConfig = config.General.Log
 		}
-		// since we can't insert at the back we've to reverse the order
-		reverseArray(instances)
-	} else {
-		log.Error(err.Error())
+		modules.SetPalette(config.General.palette())
+		if newStop, newCont := config.General.signals(); newStop != stop || newCont != cont {
+			log.Warning("Changed stop_signal and cont_signal take effect after a restart")
+		}
+		if !isFlagSet("output") && config.General.Output != general.Output {
+			log.Warning("A changed output takes effect after a restart")
+		}
+		store.SetNotice(nil)
+		ticker.Reset(interval)
+		scheduler.Reload(instances)
+		return nil
 	}
-	return
-}
-
-type CacheEntry struct {
-	ts   time.Time
-	item modules.Item
-}
 
-var cache = make(map[string]CacheEntry)
-
-func render(instances []modules.ModuleInstance) {
-	s := []string{}
-	for _, instance := range instances {
-		name := instance.Name()
-		log.Info(name)
-		var item modules.Item
-		if v, ok := cache[name]; ok {
-			if int(time.Since(v.ts).Seconds()) >= instance.RefreshInterval() {
-				item = instance.Render()
-				cache[name] = CacheEntry{ts: time.Now(), item: item}
-			} else {
-				item = v.item
+	// changes arriving in a burst are collected for a short moment so that
+	// chatty updaters don't flood the bar
+	var pending <-chan time.Time
+	paused := false
+	for {
+		select {
+		case <-ticker.C:
+		case <-store.Changed():
+			if pending == nil {
+				pending = time.After(debounceInterval)
 			}
-		} else {
-			item = instance.Render()
-			cache[name] = CacheEntry{ts: time.Now(), item: item}
+			continue
+		case <-pending:
+			pending = nil
+		case event := <-clicks:
+			scheduler.Click(event)
+			continue
+		case sig := <-sigs:
+			switch sig {
+			case stop:
+				if !paused {
+					paused = true
+					scheduler.Pause()
+				}
+			case cont:
+				if paused {
+					paused = false
+					scheduler.Resume()
+				}
+			case syscall.SIGHUP:
+				reloads.Notify()
+			case syscall.SIGUSR1:
+				scheduler.RefreshAll()
+			case syscall.SIGTERM, syscall.SIGINT:
+				log.Info("Exiting on " + sig.String())
+				scheduler.Stop()
+				return
+			default:
+				if n := int(sig.(syscall.Signal)) - sigrtmin; n >= 1 {
+					scheduler.Signal(n)
+				}
+			}
+			continue
+		case <-reloads.Notified():
+			reload()
+			continue
+		case call := <-calls:
+			call.reply <- handleCtl(call.request, scheduler, reload, reloads.mods)
+			continue
 		}
-		if item != nil {
-			s = append(s, string(item.Marshal()))
-		} else {
-			log.Error(instance.Name() + " did not return a valid item")
+		if !paused {
+			if err := backend.Write(os.Stdout, store.Blocks()); err != nil {
+				log.Error("Failed to write to stdout: " + err.Error())
+			}
 		}
 	}
-	fmt.Println("[\n" + strings.Join(s, ",\n") + "],\n")
 }
 
-type Run struct {
-	val bool
+// renderOnce renders every block once and writes a single update. The
+// slowest block is waited for, it gives up after its timeout.
+func renderOnce(w io.Writer, instances []configuredInstance, header output.Header, backend output.Backend) error {
+	scheduler := NewScheduler(instances)
+	// updaters would start servers and processes nobody waits for
+	scheduler.renderOnly = true
+	scheduler.Start()
+	defer scheduler.Stop()
+
+	store := scheduler.Store()
+	for !store.Complete() {
+		<-store.Changed()
+	}
+	if err := backend.Begin(w, header); err != nil {
+		return err
+	}
+	return backend.Write(w, store.Blocks())
 }
 
-func mainLoop(interval int64, instances []modules.ModuleInstance, run *Run) {
-
-	/*
-		*
-		*
-		{"stop_signal": 20, "click_events": true, "version": 1, "cont_signal": 18}
-		[
-		[],
-		[{"color": "#AAAAAA", "separator_block_width": 0, "name": "traffic-wl0_rx", "markup": "pango", "full_text": "_",
-		 "separator": false}, {"color": "#AAAAAA", "name": "traffic-wl0_tx", "markup": "pango", "full_text": "_"},
-		 {"color": "#FFFFFF", "name": "wireless_default", "full_text": "darmstadt.freifunk.net"},
-		 {"color": "#AAAAAA", "name": "datetime_default", "full_text": "01:33:59"}
-		],
-
-		*
-	*/
-	var preamble = `{"stop_signal": 20, "click_events": true, "version": 1, "cont_signal": 18}`
-	fmt.Println(preamble)
-
-	fmt.Println("[")
-	fmt.Println("[],")
-
-	render(instances)
-	ticker := time.NewTicker(time.Second * time.Duration(interval))
-	for range ticker.C {
-		if run.val {
-			render(instances)
-		}
+var interval = flag.Duration("interval", 2*time.Second, "emit the bar at least every `interval`, overrides the config")
+var configFormatFlag = flag.String("format", "", "`format` of the config file, one of json, yaml and toml; guessed from the extension by default")
+var watch = flag.Bool("watch", false, "reload the config whenever the file changes")
+var socket = flag.String("socket", "", "`path` of the control socket, $XDG_RUNTIME_DIR/go3status.sock by default")
+var outputFlag = flag.String("output", "", "`format` written to stdout, one of "+strings.Join(output.Names(), ", ")+"; overrides the config")
+var once = flag.Bool("once", false, "render every block once, print a single update and exit, e.g. for tmux's #()")
+var debugAddr = flag.String("debug-addr", "", "serve failure counters on http://`address`/debug/vars")
+
+// barInterval returns the interval of the bar, the flag takes precedence
+// over the config.
+func barInterval(config *Config) (time.Duration, error) {
+	if isFlagSet("interval") {
+		return *interval, nil
 	}
+	return config.General.interval(*interval)
 }
 
-func main() {
-	var mods = map[string]modules.Module{}
-	setupLogging()
+// barOutput returns the backend of the bar, the flag takes precedence over
+// the config.
+func barOutput(config *Config) (output.Backend, error) {
+	name := config.General.Output
+	if isFlagSet("output") {
+		name = *outputFlag
+	}
+	if name == "" {
+		name = "i3bar"
+	}
+	return output.New(name)
+}
 
-	log.Info("Yay! Lets rock!")
+// isFlagSet reports whether the flag was given on the command line.
+func isFlagSet(name string) (set bool) {
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return
+}
 
+func registeredModules() map[string]modules.Module {
+	var mods = map[string]modules.Module{}
 	mods["time"] = go3_time.Module
 	mods["net"] = go3_net.Module
 	mods["mpd"] = go3_mpd.Module
@@ -183,40 +267,96 @@ func main() {
 	mods["idlerpg"] = go3_idlerpg.Module
 	mods["load"] = go3_load.Module
 	mods["memory"] = go3_memory.Module
-	var config string
+	mods["push"] = go3_push.Module
+	mods["exec"] = go3_exec.Module
+	return mods
+}
 
-	if len(os.Args) > 1 {
-		if text, err := ioutil.ReadFile(os.Args[1]); err == nil {
-			config = string(text)
-		} else {
-			log.Error(err.Error())
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), `Usage:
+  %[1]s [flags] [config]   run the status bar
+  %[1]s check [config]     validate the config and exit
+  %[1]s ctl <command>      control a running instance, see "%[1]s ctl"
+  %[1]s doc                print the config reference in Markdown
+  %[1]s schema             print the JSON Schema of the config file
+
+Flags:
+`, os.Args[0])
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	mods := registeredModules()
+
+	switch flag.Arg(0) {
+	case "check":
+		if flag.NArg() > 2 {
+			usage()
+			os.Exit(2)
 		}
-	} else {
-		config = `
-[
-	{
-		"name": "default_time",
-		"module": "time"
-	},
-	{
-		"name": "default_battery",
-		"module": "battery"
-	},
-	{ 
-		"name":"wireless_network",
-		"module": "net",
-		"interface_name": "wlp3s0",
-		"format": "<span color=\"{{ if .Up }}green{{ else }}red{{end}}\">{{.Interface_name}}</span>: {{range $i, $v := .Addresses}}{{if $i}}, {{end}}{{$v}}{{end}}"
-	},
-	{
-		"name": "default_load",
-		"module": "load"
-	},
-	{
-		"name": "default_memory",
-		"module": "memory"
+		// the problems are printed, debug output would only get in the way
+		setupLogging(LogConfig{Level: "warning"})
+		fileName := flag.Arg(1)
+		if fileName == "" {
+			fileName = findConfig()
+		}
+		os.Exit(checkConfig(fileName, mods))
+	case "ctl":
+		os.Exit(runCtl(flag.Args()[1:]))
+	case "doc":
+		writeDocs(os.Stdout, mods)
+		return
+	case "schema":
+		if err := writeJSONSchema(os.Stdout, mods); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	setupLogging(LogConfig{})
+
+	if *debugAddr != "" {
+		go func() {
+			if err := http.ListenAndServe(*debugAddr, nil); err != nil {
+				log.Error("Failed to serve debug vars: " + err.Error())
+			}
+		}()
+	}
+
+	log.Info("Yay! Lets rock!")
+
+	fileName := flag.Arg(0)
+	if fileName == "" {
+		fileName = findConfig()
 	}
-]`
+	if fileName != "" {
+		log.Info("Using config " + fileName)
+	}
+
+	config, err := readConfig(fileName)
+	if err != nil {
+		log.Error("Failed to load config: " + err.Error())
+		return
+	}
+
+	if err := setupLogging(config.General.Log); err != nil {
+		log.Error("Failed to set up logging: " + err.Error())
+	}
+	modules.SetPalette(config.General.palette())
+
+	interval, err := barInterval(config)
+	if err != nil {
+		log.Error("Invalid interval: " + err.Error())
+		return
+	}
+
+	backend, err := barOutput(config)
+	if err != nil {
+		log.Error(err.Error())
+		return
 	}
 
 	//	{
@@ -234,29 +374,39 @@ func main() {
 	//		"module": "mpd",
 	//		"format": "MPD: [{{ .State }}] {{ .Artist }} - {{ .Title }}"
 	// }
-	instances := parseConfig(config, mods)
+	instances, _ := parseConfig(config, mods)
 	if len(instances) == 0 {
 		log.Error("No instances configured, exiting.")
 
+	} else if *once {
+		// a single run doesn't want to be controlled
+		stop, cont := config.General.signals()
+		header := output.Header{Stop_signal: int(stop), Cont_signal: int(cont)}
+		if err := renderOnce(os.Stdout, instances, header, backend); err != nil {
+			log.Error("Failed to write to stdout: " + err.Error())
+		}
 	} else {
 
-		var run = &Run{true}
-
-		sigs := make(chan os.Signal)
-
-		signal.Notify(sigs, syscall.SIGSTOP, syscall.SIGCONT)
-
-		go func(m *Run) {
-			for {
-				sig := <-sigs
-				if sig == syscall.SIGSTOP {
-					m.val = false
-				} else if sig == syscall.SIGCONT {
-					m.val = true
+		reloads := newReloader(fileName, mods)
+		if *watch && fileName != "" {
+			go func() {
+				files := func() []string {
+					return configFiles(fileName, *configFormatFlag)
 				}
-			}
-		}(run)
+				if err := watchFiles(files, reloads.Notify); err != nil {
+					log.Error("Failed to watch the config file: " + err.Error())
+				}
+			}()
+		}
+
+		calls := make(chan ctlCall)
+		if listener, err := listenCtl(socketPath()); err == nil {
+			defer listener.Close()
+			go serveCtl(listener, calls)
+		} else {
+			log.Error("Failed to open the control socket: " + err.Error())
+		}
 
-		mainLoop(2, instances, run)
+		mainLoop(interval, instances, config.General, backend, reloads, calls)
 	}
 }
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// envPattern matches ${NAME} and ${NAME:-default}, $${ is a literal ${.
+var envPattern = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// shell commands are left alone, the shell expands their variables when
+// the command runs, e.g. ${BLOCK_BUTTON} of a click
+var shellKeys = map[string]bool{"command": true}
+
+// expandEnv replaces environment variables in all strings of a decoded
+// config. Only the ${NAME} form is expanded since templates use $name for
+// their own variables.
+func expandEnv(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		var err error
+		expanded := envPattern.ReplaceAllStringFunc(v, func(match string) string {
+			if strings.HasPrefix(match, "$$") {
+				return match[1:]
+			}
+			groups := envPattern.FindStringSubmatch(match)
+			if env, ok := os.LookupEnv(groups[1]); ok {
+				return env
+			}
+			if groups[2] != "" {
+				return groups[3]
+			}
+			if err == nil {
+				err = errors.New("environment variable " + groups[1] + " is not set")
+			}
+			return match
+		})
+		return expanded, err
+	case map[string]interface{}:
+		for key, item := range v {
+			if _, ok := item.(string); ok && shellKeys[key] {
+				continue
+			}
+			expanded, err := expandEnv(item)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", key, err.Error())
+			}
+			v[key] = expanded
+		}
+	case []interface{}:
+		for i, item := range v {
+			expanded, err := expandEnv(item)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = expanded
+		}
+	}
+	return value, nil
+}
+
+// the deepest nesting of includes, guards against include cycles
+const maxIncludeDepth = 10
+
+// readConfigFile reads and decodes a config file, expands the environment
+// variables in it and resolves its includes. An empty format is guessed from
+// the extension.
+func readConfigFile(fileName string, format string, parents []string) (value interface{}, err error) {
+	fileName = filepath.Clean(fileName)
+	for _, parent := range parents {
+		if parent == fileName {
+			return nil, errors.New("include cycle: " + strings.Join(append(parents, fileName), " -> "))
+		}
+	}
+	if len(parents) >= maxIncludeDepth {
+		return nil, errors.New("includes nested too deeply: " + strings.Join(append(parents, fileName), " -> "))
+	}
+	if format, err = configFormat(fileName, format); err != nil {
+		return
+	}
+
+	text, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return
+	}
+	if value, err = decodeConfig(text, format); err != nil {
+		return
+	}
+	if value, err = expandEnv(value); err != nil {
+		return
+	}
+	return resolveIncludes(value, fileName, append(parents, fileName))
+}
+
+// configBlocks returns the blocks of a decoded config, which is either an
+// object or a bare list of blocks.
+func configBlocks(value interface{}) (blocks []interface{}, ok bool) {
+	switch v := value.(type) {
+	case []interface{}:
+		return v, true
+	case map[string]interface{}:
+		if v["blocks"] == nil {
+			return nil, true
+		}
+		blocks, ok = v["blocks"].([]interface{})
+	}
+	return
+}
+
+// resolveIncludes replaces the entries of the form {"include": "path"} in
+// the blocks of a config by the blocks of the included files. The path is
+// relative to the including file and may be a glob. Defaults of included
+// files apply unless the including file sets them too.
+func resolveIncludes(value interface{}, fileName string, parents []string) (interface{}, error) {
+	blocks, ok := configBlocks(value)
+	if !ok {
+		return nil, errors.New("blocks must be a list")
+	}
+
+	defaults := make(map[string]interface{})
+	var resolved []interface{}
+	for _, block := range blocks {
+		entry, ok := block.(map[string]interface{})
+		if !ok || entry["include"] == nil {
+			resolved = append(resolved, block)
+			continue
+		}
+		if len(entry) != 1 {
+			return nil, errors.New("include entries can't have other keys")
+		}
+		pattern, ok := entry["include"].(string)
+		if !ok {
+			return nil, errors.New("include must be a file name")
+		}
+
+		files, err := includedFiles(filepath.Dir(fileName), pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			included, err := readConfigFile(file, "", parents)
+			if err != nil {
+				return nil, errors.New(file + ": " + err.Error())
+			}
+			if m, ok := included.(map[string]interface{}); ok {
+				for key := range m {
+					if key != "version" && key != "defaults" && key != "blocks" {
+						return nil, errors.New(file + ": only defaults and blocks can be included, not " + key)
+					}
+				}
+				if err := mergeDefaults(defaults, m["defaults"]); err != nil {
+					return nil, errors.New(file + ": " + err.Error())
+				}
+			}
+			includedBlocks, _ := configBlocks(included)
+			resolved = append(resolved, includedBlocks...)
+		}
+	}
+
+	switch v := value.(type) {
+	case []interface{}:
+		if len(defaults) > 0 {
+			return map[string]interface{}{"defaults": defaults, "blocks": resolved}, nil
+		}
+		return resolved, nil
+	case map[string]interface{}:
+		if err := mergeDefaults(defaults, v["defaults"]); err != nil {
+			return nil, err
+		}
+		if len(defaults) > 0 {
+			v["defaults"] = defaults
+		}
+		if v["blocks"] != nil {
+			v["blocks"] = resolved
+		}
+	}
+	return value, nil
+}
+
+// includedFiles returns the files matching an include pattern. A pattern
+// without wildcards has to match an existing file.
+func includedFiles(dir string, pattern string) (files []string, err error) {
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(dir, pattern)
+	}
+	if files, err = filepath.Glob(pattern); err != nil {
+		return
+	}
+	if len(files) == 0 && !strings.ContainsAny(pattern, "*?[") {
+		err = errors.New("included file " + pattern + " doesn't exist")
+	}
+	return
+}
+
+// configFiles returns the config file and every file it includes, directly
+// or through other includes. Files that can't be read are left out along
+// with their includes, the config file itself is always returned.
+func configFiles(fileName string, format string) (files []string) {
+	seen := make(map[string]bool)
+	var walk func(fileName string, format string)
+	walk = func(fileName string, format string) {
+		fileName = filepath.Clean(fileName)
+		if seen[fileName] {
+			return
+		}
+		seen[fileName] = true
+		files = append(files, fileName)
+
+		format, err := configFormat(fileName, format)
+		if err != nil {
+			return
+		}
+		text, err := ioutil.ReadFile(fileName)
+		if err != nil {
+			return
+		}
+		value, err := decodeConfig(text, format)
+		if err != nil {
+			return
+		}
+		if value, err = expandEnv(value); err != nil {
+			return
+		}
+		blocks, _ := configBlocks(value)
+		for _, block := range blocks {
+			entry, _ := block.(map[string]interface{})
+			pattern, ok := entry["include"].(string)
+			if !ok {
+				continue
+			}
+			included, _ := includedFiles(filepath.Dir(fileName), pattern)
+			for _, file := range included {
+				walk(file, "")
+			}
+		}
+	}
+	walk(fileName, format)
+	return
+}
+
+// mergeDefaults merges the defaults of a config into merged, settings of
+// the later config take precedence.
+func mergeDefaults(merged map[string]interface{}, defaults interface{}) error {
+	if defaults == nil {
+		return nil
+	}
+	modules, ok := defaults.(map[string]interface{})
+	if !ok {
+		return errors.New("defaults must be an object")
+	}
+	for module, settings := range modules {
+		settings, ok := settings.(map[string]interface{})
+		if !ok {
+			return errors.New("defaults of " + module + " must be an object")
+		}
+		target, ok := merged[module].(map[string]interface{})
+		if !ok {
+			target = make(map[string]interface{})
+			merged[module] = target
+		}
+		for key, value := range settings {
+			target[key] = value
+		}
+	}
+	return nil
+}
+
+// findConfig looks for a config file in the go3status directories of
+// $XDG_CONFIG_HOME and $XDG_CONFIG_DIRS. It returns "" if there is none.
+func findConfig() string {
+	home := os.Getenv("XDG_CONFIG_HOME")
+	if home == "" {
+		if dir, err := os.UserHomeDir(); err == nil {
+			home = filepath.Join(dir, ".config")
+		}
+	}
+	dirs := os.Getenv("XDG_CONFIG_DIRS")
+	if dirs == "" {
+		dirs = "/etc/xdg"
+	}
+
+	for _, dir := range append([]string{home}, filepath.SplitList(dirs)...) {
+		if dir == "" {
+			continue
+		}
+		for _, name := range []string{"config.json", "config.yaml", "config.yml", "config.toml"} {
+			fileName := filepath.Join(dir, "go3status", name)
+			if _, err := os.Stat(fileName); err == nil {
+				return fileName
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("GO3STATUS_TEST", "wlan0")
+	os.Unsetenv("GO3STATUS_UNSET")
+
+	tests := []struct {
+		in   string
+		want string
+		err  bool
+	}{
+		{in: "${GO3STATUS_TEST}", want: "wlan0"},
+		{in: "if: ${GO3STATUS_TEST}!", want: "if: wlan0!"},
+		{in: "${GO3STATUS_TEST:-eth0}", want: "wlan0"},
+		{in: "${GO3STATUS_UNSET:-eth0}", want: "eth0"},
+		{in: "${GO3STATUS_UNSET:-}", want: ""},
+		{in: "${GO3STATUS_UNSET}", err: true},
+		{in: "$${GO3STATUS_TEST}", want: "${GO3STATUS_TEST}"},
+		{in: "$${GO3STATUS_UNSET}", want: "${GO3STATUS_UNSET}"},
+		{in: "$$${GO3STATUS_TEST}", want: "$${GO3STATUS_TEST}"},
+		{in: "{{ range $i, $v := .Addresses }}$v{{ end }}", want: "{{ range $i, $v := .Addresses }}$v{{ end }}"},
+		{in: "$GO3STATUS_TEST", want: "$GO3STATUS_TEST"},
+	}
+	for _, test := range tests {
+		got, err := expandEnv(test.in)
+		if test.err {
+			if err == nil {
+				t.Errorf("expandEnv(%q) = %q, want an error", test.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("expandEnv(%q) failed: %s", test.in, err)
+		} else if got != test.want {
+			t.Errorf("expandEnv(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestExpandEnvNested(t *testing.T) {
+	t.Setenv("GO3STATUS_TEST", "wlan0")
+	value := map[string]interface{}{
+		"blocks": []interface{}{
+			map[string]interface{}{"interface_name": "${GO3STATUS_TEST}", "signal": 3.0},
+			map[string]interface{}{"command": "echo ${BLOCK_BUTTON:-1} $${GO3STATUS_TEST}", "format": "${GO3STATUS_TEST}"},
+		},
+	}
+	got, err := expandEnv(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"blocks": []interface{}{
+			map[string]interface{}{"interface_name": "wlan0", "signal": 3.0},
+			// the shell expands the variables of commands when they run
+			map[string]interface{}{"command": "echo ${BLOCK_BUTTON:-1} $${GO3STATUS_TEST}", "format": "wlan0"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expandEnv = %v, want %v", got, want)
+	}
+}
+
+// writeFiles creates the files in a temporary directory and returns it.
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestResolveIncludes(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		// the names of the resolved blocks
+		blocks []string
+		// the resolved defaults
+		defaults map[string]interface{}
+		// part of the error message, if any
+		err string
+	}{
+		{
+			name: "plain",
+			files: map[string]string{
+				"config.json": `{"blocks": [{"name": "a"}, {"include": "b.json"}, {"name": "c"}]}`,
+				"b.json":      `[{"name": "b"}]`,
+			},
+			blocks: []string{"a", "b", "c"},
+		},
+		{
+			name: "glob",
+			files: map[string]string{
+				"config.json":   `[{"include": "blocks/*.json"}]`,
+				"blocks/1.json": `[{"name": "one"}]`,
+				"blocks/2.json": `{"blocks": [{"name": "two"}]}`,
+			},
+			blocks: []string{"one", "two"},
+		},
+		{
+			name: "nested relative to the including file",
+			files: map[string]string{
+				"config.json":       `[{"include": "hosts/a.json"}]`,
+				"hosts/a.json":      `[{"include": "common.json"}]`,
+				"hosts/common.json": `[{"name": "common"}]`,
+			},
+			blocks: []string{"common"},
+		},
+		{
+			name: "defaults of the including file take precedence",
+			files: map[string]string{
+				"config.json": `{"defaults": {"time": {"color": "#FFFFFF"}}, "blocks": [{"include": "a.json"}]}`,
+				"a.json":      `{"defaults": {"time": {"color": "#000000", "format": "15:04"}, "load": {"color": "#111111"}}, "blocks": []}`,
+			},
+			defaults: map[string]interface{}{
+				"time": map[string]interface{}{"color": "#FFFFFF", "format": "15:04"},
+				"load": map[string]interface{}{"color": "#111111"},
+			},
+		},
+		{
+			name: "later includes take precedence",
+			files: map[string]string{
+				"config.json": `[{"include": "a.json"}, {"include": "b.json"}]`,
+				"a.json":      `{"defaults": {"time": {"color": "#000000"}}}`,
+				"b.json":      `{"defaults": {"time": {"color": "#FFFFFF"}}}`,
+			},
+			defaults: map[string]interface{}{
+				"time": map[string]interface{}{"color": "#FFFFFF"},
+			},
+		},
+		{
+			name: "cycle",
+			files: map[string]string{
+				"config.json": `[{"include": "a.json"}]`,
+				"a.json":      `[{"include": "config.json"}]`,
+			},
+			err: "include cycle",
+		},
+		{
+			name: "self",
+			files: map[string]string{
+				"config.json": `[{"include": "config.json"}]`,
+			},
+			err: "include cycle",
+		},
+		{
+			name: "missing file",
+			files: map[string]string{
+				"config.json": `[{"include": "missing.json"}]`,
+			},
+			err: "doesn't exist",
+		},
+		{
+			name: "empty glob",
+			files: map[string]string{
+				"config.json": `[{"name": "a"}, {"include": "blocks/*.json"}]`,
+			},
+			blocks: []string{"a"},
+		},
+		{
+			name: "other keys next to include",
+			files: map[string]string{
+				"config.json": `[{"include": "a.json", "name": "a"}]`,
+				"a.json":      `[]`,
+			},
+			err: "can't have other keys",
+		},
+		{
+			name: "general in an included file",
+			files: map[string]string{
+				"config.json": `[{"include": "a.json"}]`,
+				"a.json":      `{"general": {}}`,
+			},
+			err: "only defaults and blocks",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := writeFiles(t, test.files)
+			value, err := readConfigFile(filepath.Join(dir, "config.json"), "", nil)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got error %v, want one containing %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			blocks, _ := configBlocks(value)
+			var names []string
+			for _, block := range blocks {
+				names = append(names, block.(map[string]interface{})["name"].(string))
+			}
+			if !reflect.DeepEqual(names, test.blocks) {
+				t.Errorf("blocks = %v, want %v", names, test.blocks)
+			}
+
+			var defaults interface{}
+			if m, ok := value.(map[string]interface{}); ok {
+				defaults = m["defaults"]
+			}
+			if test.defaults == nil {
+				if defaults != nil {
+					t.Errorf("defaults = %v, want none", defaults)
+				}
+			} else if !reflect.DeepEqual(defaults, test.defaults) {
+				t.Errorf("defaults = %v, want %v", defaults, test.defaults)
+			}
+		})
+	}
+}
+
+func TestConfigFiles(t *testing.T) {
+	t.Setenv("GO3STATUS_HOST", "laptop")
+	dir := writeFiles(t, map[string]string{
+		"config.yaml":       "blocks:\n  - include: hosts/${GO3STATUS_HOST}.json\n  - include: blocks/*.toml\n  - name: a\n",
+		"hosts/laptop.json": `[{"include": "../common.json"}, {"include": "missing.json"}]`,
+		"common.json":       `[{"include": "config.yaml"}, {"name": "b"}]`,
+		"blocks/1.toml":     "[[blocks]]\nname = \"c\"\n",
+		"blocks/2.toml":     "broken = [",
+		"unused.json":       `[]`,
+	})
+	want := []string{"config.yaml", "hosts/laptop.json", "common.json", "blocks/1.toml", "blocks/2.toml"}
+	for i, name := range want {
+		want[i] = filepath.Join(dir, name)
+	}
+	if got := configFiles(filepath.Join(dir, "config.yaml"), ""); !reflect.DeepEqual(got, want) {
+		t.Errorf("configFiles = %v, want %v", got, want)
+	}
+
+	missing := filepath.Join(dir, "missing.json")
+	if got := configFiles(missing, ""); !reflect.DeepEqual(got, []string{missing}) {
+		t.Errorf("configFiles of a missing file = %v", got)
+	}
+}
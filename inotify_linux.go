@@ -0,0 +1,76 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// watchFiles calls changed whenever one of the files returned by files has
+// been written or replaced. files is asked again after every change since
+// the config may include other files by then. The directories are watched
+// instead of the files since most editors replace the file instead of
+// writing to it.
+func watchFiles(files func() []string, changed func()) (err error) {
+	var fd int
+	if fd, err = syscall.InotifyInit1(syscall.IN_CLOEXEC); err != nil {
+		return
+	}
+	defer syscall.Close(fd)
+
+	// the watched directories by watch descriptor
+	dirs := make(map[int32]string)
+	var watched map[string]bool
+	update := func() error {
+		watched = make(map[string]bool)
+		for _, fileName := range files() {
+			if resolved, err := filepath.EvalSymlinks(fileName); err == nil {
+				fileName = resolved
+			}
+			if abs, err := filepath.Abs(fileName); err == nil {
+				fileName = abs
+			}
+			watched[fileName] = true
+
+			// watching a directory again returns the same descriptor
+			dir := filepath.Dir(fileName)
+			wd, err := syscall.InotifyAddWatch(fd, dir, syscall.IN_CLOSE_WRITE|syscall.IN_MOVED_TO)
+			if err != nil {
+				return err
+			}
+			dirs[int32(wd)] = dir
+		}
+		return nil
+	}
+	if err = update(); err != nil {
+		return
+	}
+
+	buf := make([]byte, 4096)
+	for {
+		n, e := syscall.Read(fd, buf)
+		if e == syscall.EINTR {
+			continue
+		} else if e != nil {
+			return e
+		}
+
+		matched := false
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			start := offset + syscall.SizeofInotifyEvent
+			offset = start + int(event.Len)
+			name := strings.TrimRight(string(buf[start:offset]), "\x00")
+			if watched[filepath.Join(dirs[event.Wd], name)] {
+				matched = true
+			}
+		}
+		if matched {
+			changed()
+			if err := update(); err != nil {
+				log.Warning("Failed to watch the included files: " + err.Error())
+			}
+		}
+	}
+}
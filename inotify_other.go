@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+import "errors"
+
+// watchFiles needs inotify, -watch only works on Linux.
+func watchFiles(files func() []string, changed func()) error {
+	return errors.New("watching the config file is only supported on Linux")
+}
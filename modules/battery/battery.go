@@ -2,7 +2,9 @@ package battery
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"github.com/andir/go3status/modules"
 	"github.com/op/go-logging"
 	"io/ioutil"
@@ -10,32 +12,19 @@ import (
 	"strconv"
 	"strings"
 	"text/template"
+	"time"
 )
 
 var log = logging.MustGetLogger("go3status.battery")
 
-type BatteryItem struct {
-	Name   string `json:"name"`
-	Text   string `json:"full_text"`
-	Markup string `json:"markup"`
-}
-
-func (e BatteryItem) Marshal() (bytes []byte) {
-	var err error
-	if bytes, err = json.Marshal(e); err != nil {
-		log.Error(err.Error())
-	}
-	return
-}
-
 type BatteryInstance struct {
 	name        string
 	device_path string
 	template    *template.Template
 }
 
-func (i BatteryInstance) RefreshInterval() int {
-	return 5
+func (i BatteryInstance) RefreshInterval() time.Duration {
+	return 5 * time.Second
 }
 
 func (i BatteryInstance) Name() string {
@@ -69,12 +58,12 @@ type BatteryInfo struct {
 	Percentage         float64 `json:"Percentage"`
 }
 
-func NewBatteryInfo(fileName string) *BatteryInfo {
+func NewBatteryInfo(fileName string) (*BatteryInfo, error) {
 	info := &BatteryInfo{}
+	hasCapacity := false
 
 	if v, err := ioutil.ReadFile(fileName); err != nil {
-		log.Error(err.Error())
-		return nil
+		return nil, err
 	} else {
 		s := string(v)
 		s = strings.TrimSpace(s)
@@ -125,6 +114,7 @@ func NewBatteryInfo(fileName string) *BatteryInfo {
 			case "POWER_SUPPLY_CAPACITY":
 				if v, err := strconv.Atoi(val); err == nil {
 					info.Capacity = v
+					hasCapacity = true
 				}
 			case "POWER_SUPPLY_CAPACITY_LEVEL":
 				info.Capacity_level = val
@@ -135,27 +125,34 @@ func NewBatteryInfo(fileName string) *BatteryInfo {
 			case "POWER_SUPPLY_SERIAL_NUMBER":
 				info.Serial_number = val
 			}
-
-			charge := float64(info.Energy_now) / float64(info.Energy_full)
-			charge *= 100
-			info.Percentage = charge
 		}
 	}
-	return info
+
+	// not every battery reports its energy, some only know the capacity
+	if info.Energy_full > 0 {
+		info.Percentage = float64(info.Energy_now) / float64(info.Energy_full) * 100
+	} else if hasCapacity {
+		info.Percentage = float64(info.Capacity)
+	} else {
+		return nil, errors.New("no charge level in " + fileName)
+	}
+	return info, nil
 }
 
-func (i BatteryInstance) Render() (item modules.Item) {
-	it := BatteryItem{
+func (i BatteryInstance) Render(ctx context.Context) (block *modules.Block, err error) {
+	b := &modules.Block{
 		Name: i.name,
 	}
 
 	if i.template == nil {
-		log.Error("No template available.")
-		item = nil
+		err = errors.New("no template available")
 		return
 	}
 
-	info := NewBatteryInfo(i.device_path)
+	info, err := NewBatteryInfo(i.device_path)
+	if err != nil {
+		return
+	}
 
 	if b, err := json.Marshal(info); err != nil {
 		log.Error("Failed to marshal info.")
@@ -164,43 +161,38 @@ func (i BatteryInstance) Render() (item modules.Item) {
 	}
 
 	buffer := bytes.Buffer{}
-	if err := i.template.Execute(&buffer, info); err != nil {
-		log.Error(err.Error())
-		item = nil
+	if err = i.template.Execute(&buffer, info); err != nil {
 		return
 	} else {
-		it.Text = buffer.String()
+		b.Full_text = buffer.String()
 	}
+	b.Metrics = map[string]float64{"percentage": info.Percentage}
 
-	item = it
+	block = b
 	return
 }
 
-func CreateInstance(name string, config map[string]interface{}) (instance modules.ModuleInstance) {
-	batteryInstance := BatteryInstance{
-		name: name,
-	}
-
-	if v, ok := config["device_path"]; ok {
-		batteryInstance.device_path = v.(string)
-	} else {
-		batteryInstance.device_path = "/sys/class/power_supply/BAT0/uevent"
-	}
+const defaultFormat = `{{.Name}}: {{printf "%.1f" .Percentage}} % {{ if Equal .Status "Charging" }}⚇{{ end }}`
 
-	var format string
+type Config struct {
+	Device_path string `config:"device_path" default:"/sys/class/power_supply/BAT0/uevent" doc:"uevent file of the battery"`
+	Format      string `config:"format" doc:"template rendered with the BatteryInfo"`
+	Markup      string `block:"markup"`
+}
 
-	if v, ok := config["format"]; ok {
-		format = v.(string)
-	} else {
-		format = `{{.Name}}: {{printf "%.1f" .Percentage}} % {{ if Equal .Status "Charging" }}⚇{{ end }}`
+func CreateInstance(name string, config interface{}) (instance modules.ModuleInstance, err error) {
+	c := config.(*Config)
+	batteryInstance := BatteryInstance{
+		name:        name,
+		device_path: c.Device_path,
 	}
 
-	if tmpl, err := template.New(name).Funcs(template.FuncMap{
+	if tmpl, err := modules.NewTemplate(name, c.Format, c.Markup, template.FuncMap{
 		"Equal": strings.EqualFold,
-	}).Parse(format); err == nil {
+	}); err == nil {
 		batteryInstance.template = tmpl
 	} else {
-		log.Error(err.Error())
+		return nil, &modules.FieldError{Key: "format", Err: err}
 	}
 
 	instance = batteryInstance
@@ -210,4 +202,8 @@ func CreateInstance(name string, config map[string]interface{}) (instance module
 var Module = modules.Module{
 	Name:           "battery",
 	CreateInstance: CreateInstance,
+	Metrics:        []string{"percentage"},
+	NewConfig: func() interface{} {
+		return &Config{Format: defaultFormat}
+	},
 }
@@ -0,0 +1,25 @@
+package battery
+
+import (
+	"bytes"
+	"syscall"
+
+	"github.com/andir/go3status/modules"
+)
+
+// the kernel broadcasts uevents on the first multicast group
+const ueventGroup = 1
+
+// Watch re-renders the block whenever a power supply uevent is received,
+// e.g. when the charger gets plugged in.
+func (i BatteryInstance) Watch(update modules.UpdateFunc, refresh modules.RefreshFunc, done <-chan struct{}) {
+	err := modules.WatchNetlink(syscall.NETLINK_KOBJECT_UEVENT, ueventGroup, func(msg []byte) {
+		if bytes.Contains(msg, []byte("SUBSYSTEM=power_supply")) {
+			refresh()
+		}
+	}, done)
+
+	if err != nil {
+		log.Error("Failed to watch power supply events: " + err.Error())
+	}
+}
@@ -0,0 +1,84 @@
+package modules
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// Block is a single block of the i3bar protocol as documented in
+// https://i3wm.org/docs/i3bar-protocol.html. swaybar understands the same
+// fields.
+type Block struct {
+	Full_text  string `json:"full_text"`
+	Short_text string `json:"short_text,omitempty"`
+	Color      string `json:"color,omitempty"`
+	Background string `json:"background,omitempty"`
+	Border     string `json:"border,omitempty"`
+	// the border widths default to 1 in i3bar, hence the pointers
+	Border_top    *int `json:"border_top,omitempty"`
+	Border_right  *int `json:"border_right,omitempty"`
+	Border_bottom *int `json:"border_bottom,omitempty"`
+	Border_left   *int `json:"border_left,omitempty"`
+	// either a width in pixels or a string whose width is used
+	Min_width             interface{} `json:"min_width,omitempty"`
+	Align                 string      `json:"align,omitempty"`
+	Urgent                bool        `json:"urgent,omitempty"`
+	Name                  string      `json:"name,omitempty"`
+	Instance              string      `json:"instance,omitempty"`
+	Separator             *bool       `json:"separator,omitempty"`
+	Separator_block_width *int        `json:"separator_block_width,omitempty"`
+	Markup                string      `json:"markup,omitempty"`
+	// values the module measured, for thresholds; never sent to the bar
+	Metrics map[string]float64 `json:"-"`
+}
+
+func (b Block) Marshal() (bytes []byte) {
+	var err error
+	if bytes, err = json.Marshal(b); err != nil {
+		log.Error(err.Error())
+	}
+	return
+}
+
+// blockKeys are the config keys that can override a field of the block.
+// The name is left out since it identifies the instance.
+var blockKeys = func() (keys map[string]bool) {
+	keys = make(map[string]bool)
+	t := reflect.TypeOf(Block{})
+	for i := 0; i < t.NumField(); i++ {
+		key := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if key != "name" && key != "-" {
+			keys[key] = true
+		}
+	}
+	return
+}()
+
+// IsBlockKey reports whether the config key overrides a field of the block.
+func IsBlockKey(key string) bool {
+	return blockKeys[key]
+}
+
+// BlockOverrides picks the block fields out of an instance config.
+func BlockOverrides(config map[string]interface{}) (overrides map[string]interface{}) {
+	overrides = make(map[string]interface{})
+	for key, value := range config {
+		if IsBlockKey(key) {
+			overrides[key] = value
+		}
+	}
+	return
+}
+
+// Apply overwrites the fields of the block that are set in overrides.
+func (b *Block) Apply(overrides map[string]interface{}) error {
+	if len(overrides) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(overrides)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, b)
+}
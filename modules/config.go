@@ -0,0 +1,299 @@
+package modules
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Module configs are plain structs, every exported field is a config key.
+// The field is described by these tags:
+//
+//	config    the config key, defaults to the lower case field name
+//	default   the value used when the key is missing
+//	doc       a short description
+//	required  "true" if the key has to be set
+//	allowed   comma separated list of the values a string may take
+//	block     fills the field from a block setting like "markup" instead,
+//	          such fields are not keys of the module
+//
+// Defaults that are unwieldy as a tag, like templates, can be set in the
+// struct returned by NewConfig instead.
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// FieldError is a problem with a single config key.
+type FieldError struct {
+	Key string
+	Err error
+}
+
+func (e *FieldError) Error() string {
+	return e.Key + ": " + e.Err.Error()
+}
+
+// configKey returns the config key of a struct field, falling back to the
+// json tag and the field name.
+func configKey(f reflect.StructField) string {
+	if key := f.Tag.Get("config"); key != "" {
+		return key
+	}
+	if key := strings.Split(f.Tag.Get("json"), ",")[0]; key != "" {
+		return key
+	}
+	return strings.ToLower(f.Name)
+}
+
+func fieldType(t reflect.Type) FieldType {
+	if t == durationType {
+		return Duration
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return fieldType(t.Elem())
+	case reflect.String:
+		return String
+	case reflect.Bool:
+		return Bool
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Int
+	case reflect.Float32, reflect.Float64:
+		return Number
+	case reflect.Slice:
+		return List
+	case reflect.Map, reflect.Struct:
+		return Object
+	}
+	return Any
+}
+
+// configFields calls fn for every config field of the struct config points
+// to.
+func configFields(config interface{}, fn func(key string, f reflect.StructField, v reflect.Value) error) error {
+	v := reflect.ValueOf(config)
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		key := configKey(f)
+		if f.PkgPath != "" || key == "-" || f.Tag.Get("block") != "" {
+			continue
+		}
+		if err := fn(key, f, v.Field(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SchemaOf describes the config struct config points to.
+func SchemaOf(config interface{}) (schema Schema) {
+	schema = make(Schema)
+	configFields(config, func(key string, f reflect.StructField, v reflect.Value) error {
+		field := Field{
+			Type:     fieldType(f.Type),
+			Required: f.Tag.Get("required") == "true",
+			Doc:      f.Tag.Get("doc"),
+		}
+		if allowed := f.Tag.Get("allowed"); allowed != "" {
+			field.Allowed = strings.Split(allowed, ",")
+		}
+		if def, ok := f.Tag.Lookup("default"); ok {
+			field.Default = def
+		} else if !v.IsZero() {
+			field.Default = v.Interface()
+		}
+		schema[key] = field
+		return nil
+	})
+	return
+}
+
+// DecodeConfig fills the struct config points to from the raw config of a
+// block. Keys that aren't fields of the struct are ignored, missing keys
+// keep the value already set or get the default of their tag.
+func DecodeConfig(raw map[string]interface{}, config interface{}) error {
+	decodeBlockFields(raw, config)
+	return configFields(config, func(key string, f reflect.StructField, v reflect.Value) error {
+		value, ok := raw[key]
+		if !ok {
+			if f.Tag.Get("required") == "true" {
+				return &FieldError{key, errors.New("missing")}
+			}
+			if def, ok := f.Tag.Lookup("default"); ok {
+				if err := setFromString(v, def); err != nil {
+					return &FieldError{key, errors.New("invalid default: " + err.Error())}
+				}
+			}
+			return nil
+		}
+
+		field := Field{Type: fieldType(f.Type)}
+		if allowed := f.Tag.Get("allowed"); allowed != "" {
+			field.Allowed = strings.Split(allowed, ",")
+		}
+		if err := field.Check(value); err != nil {
+			return &FieldError{key, err}
+		}
+		if err := setValue(v, value); err != nil {
+			return &FieldError{key, err}
+		}
+		return nil
+	})
+}
+
+// decodeBlockFields fills the string fields with a block tag from the
+// block settings they name, unset settings keep the value of the field.
+func decodeBlockFields(raw map[string]interface{}, config interface{}) {
+	v := reflect.ValueOf(config)
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		key := t.Field(i).Tag.Get("block")
+		if key == "" || t.Field(i).Type.Kind() != reflect.String {
+			continue
+		}
+		if value, ok := raw[key].(string); ok && value != "" {
+			v.Field(i).SetString(value)
+		}
+	}
+}
+
+// setValue assigns a value that passed Field.Check.
+func setValue(v reflect.Value, value interface{}) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(value.(string))
+		v.SetInt(int64(d))
+		return err
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		p := reflect.New(v.Type().Elem())
+		if err := setValue(p.Elem(), value); err != nil {
+			return err
+		}
+		v.Set(p)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, _ := ToInt(value)
+		v.SetInt(int64(n))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, _ := ToInt(value)
+		if n < 0 {
+			return errors.New("must not be negative")
+		}
+		v.SetUint(uint64(n))
+	case reflect.Float32, reflect.Float64:
+		f, _ := ToFloat(value)
+		v.SetFloat(f)
+	case reflect.Slice:
+		list := reflect.ValueOf(value)
+		slice := reflect.MakeSlice(v.Type(), list.Len(), list.Len())
+		for i := 0; i < list.Len(); i++ {
+			item := list.Index(i).Interface()
+			if err := (Field{Type: fieldType(v.Type().Elem())}).Check(item); err != nil {
+				return errors.New("item " + strconv.Itoa(i) + " " + err.Error())
+			}
+			if err := setValue(slice.Index(i), item); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	case reflect.Map:
+		object := reflect.ValueOf(value)
+		if object.Type().Key().Kind() != reflect.String || v.Type().Key().Kind() != reflect.String {
+			return errors.New("has the wrong type")
+		}
+		m := reflect.MakeMapWithSize(v.Type(), object.Len())
+		iter := object.MapRange()
+		for iter.Next() {
+			key := iter.Key().String()
+			item := iter.Value().Interface()
+			if err := (Field{Type: fieldType(v.Type().Elem())}).Check(item); err != nil {
+				return errors.New("item " + key + " " + err.Error())
+			}
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := setValue(elem, item); err != nil {
+				return err
+			}
+			m.SetMapIndex(reflect.ValueOf(key).Convert(v.Type().Key()), elem)
+		}
+		v.Set(m)
+	default:
+		given := reflect.ValueOf(value)
+		if !given.IsValid() {
+			return nil
+		}
+		if !given.Type().AssignableTo(v.Type()) {
+			if !given.Type().ConvertibleTo(v.Type()) {
+				return errors.New("has the wrong type")
+			}
+			given = given.Convert(v.Type())
+		}
+		v.Set(given)
+	}
+	return nil
+}
+
+// setFromString assigns the value of a default tag.
+func setFromString(v reflect.Value, s string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		v.SetInt(int64(d))
+		return err
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		p := reflect.New(v.Type().Elem())
+		if err := setFromString(p.Elem(), s); err != nil {
+			return err
+		}
+		v.Set(p)
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		items := strings.Split(s, ",")
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setFromString(slice.Index(i), item); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	default:
+		return errors.New("no defaults for " + v.Type().String())
+	}
+	return nil
+}
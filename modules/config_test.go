@@ -0,0 +1,174 @@
+package modules
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testConfig struct {
+	Name     string            `config:"name" required:"true"`
+	Mode     string            `config:"mode" default:"text" allowed:"text,json"`
+	Port     int               `config:"port" default:"6600"`
+	Count    uint              `config:"count"`
+	Ratio    float64           `config:"ratio"`
+	Enabled  bool              `config:"enabled" default:"true"`
+	Timeout  time.Duration     `config:"timeout" default:"3s"`
+	Limit    *int              `config:"limit"`
+	Tags     []string          `config:"tags"`
+	Ports    []int             `config:"ports"`
+	Env      map[string]string `config:"env"`
+	Format   string            `config:"format"`
+	Markup   string            `block:"markup"`
+	Ignored  string            `config:"-"`
+	internal string
+}
+
+func newTestConfig() *testConfig {
+	return &testConfig{Format: "{{ .Name }}", Markup: "pango"}
+}
+
+func intPtr(n int) *int {
+	return &n
+}
+
+func TestDecodeConfig(t *testing.T) {
+	defaults := testConfig{
+		Name:    "a",
+		Mode:    "text",
+		Port:    6600,
+		Enabled: true,
+		Timeout: 3 * time.Second,
+		Format:  "{{ .Name }}",
+		Markup:  "pango",
+	}
+
+	tests := []struct {
+		name string
+		raw  map[string]interface{}
+		// changes the defaults into the expected config
+		want func(c *testConfig)
+		// the key of the expected FieldError, if any
+		err string
+	}{
+		{
+			name: "defaults",
+			raw:  map[string]interface{}{"name": "a"},
+			want: func(c *testConfig) {},
+		},
+		{
+			name: "values",
+			raw: map[string]interface{}{
+				"name":    "a",
+				"mode":    "json",
+				"port":    7000.0,
+				"count":   2.0,
+				"ratio":   0.5,
+				"enabled": false,
+				"timeout": "1m",
+				"limit":   5.0,
+				"tags":    []interface{}{"x", "y"},
+				"ports":   []interface{}{1.0, 2.0},
+				"env":     map[string]interface{}{"A": "b"},
+				"format":  "{{ .Port }}",
+			},
+			want: func(c *testConfig) {
+				c.Mode = "json"
+				c.Port = 7000
+				c.Count = 2
+				c.Ratio = 0.5
+				c.Enabled = false
+				c.Timeout = time.Minute
+				c.Limit = intPtr(5)
+				c.Tags = []string{"x", "y"}
+				c.Ports = []int{1, 2}
+				c.Env = map[string]string{"A": "b"}
+				c.Format = "{{ .Port }}"
+			},
+		},
+		{
+			name: "integers from YAML and TOML",
+			raw:  map[string]interface{}{"name": "a", "port": 7000, "count": int64(2), "ratio": 1},
+			want: func(c *testConfig) {
+				c.Port = 7000
+				c.Count = 2
+				c.Ratio = 1
+			},
+		},
+		{
+			name: "unknown and ignored keys",
+			raw:  map[string]interface{}{"name": "a", "color": "#FFFFFF", "-": "x", "internal": "x", "ignored": "x"},
+			want: func(c *testConfig) {},
+		},
+		{
+			name: "block settings",
+			raw:  map[string]interface{}{"name": "a", "markup": "none"},
+			want: func(c *testConfig) { c.Markup = "none" },
+		},
+		{
+			name: "empty block setting",
+			raw:  map[string]interface{}{"name": "a", "markup": ""},
+			want: func(c *testConfig) {},
+		},
+		{name: "missing", raw: map[string]interface{}{}, err: "name"},
+		{name: "not allowed", raw: map[string]interface{}{"name": "a", "mode": "xml"}, err: "mode"},
+		{name: "string for int", raw: map[string]interface{}{"name": "a", "port": "80"}, err: "port"},
+		{name: "fraction for int", raw: map[string]interface{}{"name": "a", "port": 80.5}, err: "port"},
+		{name: "negative uint", raw: map[string]interface{}{"name": "a", "count": -1.0}, err: "count"},
+		{name: "number for bool", raw: map[string]interface{}{"name": "a", "enabled": 1.0}, err: "enabled"},
+		{name: "invalid duration", raw: map[string]interface{}{"name": "a", "timeout": "3 seconds"}, err: "timeout"},
+		{name: "number for duration", raw: map[string]interface{}{"name": "a", "timeout": 3.0}, err: "timeout"},
+		{name: "wrong item", raw: map[string]interface{}{"name": "a", "ports": []interface{}{1.0, "2"}}, err: "ports"},
+		{name: "string for list", raw: map[string]interface{}{"name": "a", "tags": "x"}, err: "tags"},
+		{name: "wrong map value", raw: map[string]interface{}{"name": "a", "env": map[string]interface{}{"A": 1.0}}, err: "env"},
+	}
+	for _, test := range tests {
+		c := newTestConfig()
+		err := DecodeConfig(test.raw, c)
+		if test.err != "" {
+			var fieldErr *FieldError
+			if !errors.As(err, &fieldErr) || fieldErr.Key != test.err {
+				t.Errorf("%s: got error %v, want one for %s", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		want := defaults
+		test.want(&want)
+		if !reflect.DeepEqual(*c, want) {
+			t.Errorf("%s: got %+v, want %+v", test.name, *c, want)
+		}
+	}
+}
+
+func TestSchemaOf(t *testing.T) {
+	schema := SchemaOf(newTestConfig())
+	for _, key := range []string{"markup", "-", "ignored", "internal"} {
+		if _, ok := schema[key]; ok {
+			t.Errorf("%s is in the schema", key)
+		}
+	}
+
+	tests := []struct {
+		key  string
+		want Field
+	}{
+		{"name", Field{Type: String, Required: true}},
+		{"mode", Field{Type: String, Default: "text", Allowed: []string{"text", "json"}}},
+		{"port", Field{Type: Int, Default: "6600"}},
+		{"timeout", Field{Type: Duration, Default: "3s"}},
+		{"limit", Field{Type: Int}},
+		{"tags", Field{Type: List}},
+		{"env", Field{Type: Object}},
+		{"format", Field{Type: String, Default: "{{ .Name }}"}},
+	}
+	for _, test := range tests {
+		if got := schema[test.key]; !reflect.DeepEqual(got, test.want) {
+			t.Errorf("schema[%q] = %+v, want %+v", test.key, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package exec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	os_exec "os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/andir/go3status/modules"
+	"github.com/op/go-logging"
+)
+
+var log = logging.MustGetLogger("go3status.exec")
+
+type Config struct {
+	Command string `config:"command" required:"true" doc:"shell command whose output is shown"`
+	// how stdout is turned into a block
+	Output           string `config:"output" default:"i3blocks" allowed:"text,i3blocks,json" doc:"text uses all of stdout as full_text, i3blocks reads full_text, short_text, color and background from the first lines, json a whole block"`
+	Urgent_exit_code int    `config:"urgent_exit_code" default:"33" doc:"exit code that marks the block as urgent instead of failed"`
+	// see persist.go
+	Persist bool `config:"persist" doc:"keep the command running and show every line it prints"`
+}
+
+type ExecInstance struct {
+	name   string
+	config *Config
+	// the click that triggered the next render, if any
+	click *modules.ClickEvent
+	// state of the running command in persist mode
+	persistent
+}
+
+func (t *ExecInstance) RefreshInterval() time.Duration {
+	return 10 * time.Second
+}
+
+func (t *ExecInstance) Name() (n string) {
+	n = t.name
+	return
+}
+
+func (t *ExecInstance) String() (s string) {
+	s = t.Name()
+	return
+}
+
+// Click runs the command right away with the click in its environment,
+// like i3blocks does.
+func (t *ExecInstance) Click(event modules.ClickEvent) {
+	if t.config.Persist {
+		t.sendClick(event)
+		return
+	}
+	t.click = &event
+}
+
+// environment passes the name of the block and the click that triggered
+// the render to the command.
+func (t *ExecInstance) environment() (env []string) {
+	env = append(os.Environ(), "BLOCK_NAME="+t.name)
+	if t.click == nil {
+		return
+	}
+
+	c := t.click
+	values := map[string]string{
+		"instance":   c.Instance,
+		"button":     strconv.Itoa(c.Button),
+		"modifiers":  strings.Join(c.Modifiers, ","),
+		"x":          strconv.Itoa(c.X),
+		"y":          strconv.Itoa(c.Y),
+		"relative_x": strconv.Itoa(c.Relative_x),
+		"relative_y": strconv.Itoa(c.Relative_y),
+		"width":      strconv.Itoa(c.Width),
+		"height":     strconv.Itoa(c.Height),
+	}
+	for key, value := range values {
+		// i3blocks sets both forms
+		env = append(env, key+"="+value, "BLOCK_"+strings.ToUpper(key)+"="+value)
+	}
+	return
+}
+
+// command prepares the shell running the command in its own process group,
+// so that the whole group can be killed once ctx is done.
+func (t *ExecInstance) command(env []string) *os_exec.Cmd {
+	cmd := os_exec.Command("/bin/sh", "-c", t.config.Command)
+	cmd.Env = env
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	return cmd
+}
+
+// killGroup kills the process group of a started command.
+func killGroup(cmd *os_exec.Cmd) {
+	if cmd.Process != nil {
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// run runs the command and returns its stdout.
+func (t *ExecInstance) run(ctx context.Context, env []string) (stdout []byte, exitCode int, err error) {
+	var out, stderr bytes.Buffer
+	cmd := t.command(env)
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+
+	if err = cmd.Start(); err != nil {
+		return
+	}
+
+	finished := make(chan error, 1)
+	go func() {
+		finished <- cmd.Wait()
+	}()
+
+	select {
+	case err = <-finished:
+	case <-ctx.Done():
+		killGroup(cmd)
+		<-finished
+		return nil, -1, ctx.Err()
+	}
+
+	stdout = out.Bytes()
+	if exitErr, ok := err.(*os_exec.ExitError); ok {
+		exitCode = exitErr.ExitCode()
+		err = nil
+		if exitCode != t.config.Urgent_exit_code {
+			message := strings.TrimSpace(stderr.String())
+			if message == "" {
+				message = exitErr.Error()
+			}
+			err = errors.New(message)
+		}
+	}
+	return
+}
+
+func (t *ExecInstance) Render(ctx context.Context) (block *modules.Block, err error) {
+	if t.config.Persist {
+		return t.lastBlock()
+	}
+
+	env := t.environment()
+	t.click = nil
+
+	stdout, exitCode, err := t.run(ctx, env)
+	if err != nil {
+		return
+	}
+
+	if block, err = parseOutput(stdout, t.config.Output); err != nil || block == nil {
+		return
+	}
+	block.Name = t.name
+	if exitCode != 0 && exitCode == t.config.Urgent_exit_code {
+		block.Urgent = true
+	}
+	return
+}
+
+// parseOutput turns the output of the command into a block, empty output
+// hides the block.
+func parseOutput(stdout []byte, output string) (block *modules.Block, err error) {
+	text := strings.TrimRight(string(stdout), "\n")
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+
+	block = new(modules.Block)
+	switch output {
+	case "json":
+		if err = json.Unmarshal([]byte(text), block); err != nil {
+			return nil, errors.New("invalid JSON output: " + err.Error())
+		}
+		if block.Full_text == "" {
+			return nil, nil
+		}
+	case "text":
+		block.Full_text = text
+	default:
+		// full_text, short_text, color and background on separate lines
+		lines := strings.Split(text, "\n")
+		fields := []*string{&block.Full_text, &block.Short_text, &block.Color, &block.Background}
+		for i := 0; i < len(lines) && i < len(fields); i++ {
+			*fields[i] = lines[i]
+		}
+	}
+	return
+}
+
+func CreateInstance(name string, config interface{}) (instance modules.ModuleInstance, err error) {
+	c := config.(*Config)
+	instance = &ExecInstance{
+		name:   name,
+		config: c,
+	}
+	return
+}
+
+var Module = modules.Module{
+	Name:           "exec",
+	CreateInstance: CreateInstance,
+	NewConfig:      func() interface{} { return &Config{} },
+}
@@ -0,0 +1,47 @@
+package exec
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/andir/go3status/modules"
+)
+
+func TestParseOutput(t *testing.T) {
+	tests := []struct {
+		name   string
+		stdout string
+		output string
+		want   *modules.Block
+		err    bool
+	}{
+		{"empty", "", "i3blocks", nil, false},
+		{"blank lines", "\n  \n", "text", nil, false},
+		{"i3blocks full_text", "42\n", "i3blocks", &modules.Block{Full_text: "42"}, false},
+		{"i3blocks all lines", "long\nshort\n#FF0000\n#000000\nignored\n", "i3blocks",
+			&modules.Block{Full_text: "long", Short_text: "short", Color: "#FF0000", Background: "#000000"}, false},
+		{"i3blocks color only", "long\n\n#FF0000", "i3blocks", &modules.Block{Full_text: "long", Color: "#FF0000"}, false},
+		{"unknown output is i3blocks", "a\nb", "", &modules.Block{Full_text: "a", Short_text: "b"}, false},
+		{"text keeps lines", "a\nb\n\n", "text", &modules.Block{Full_text: "a\nb"}, false},
+		{"text keeps spaces", "  a ", "text", &modules.Block{Full_text: "  a "}, false},
+		{"json", `{"full_text": "a", "color": "#00FF00", "urgent": true}`, "json",
+			&modules.Block{Full_text: "a", Color: "#00FF00", Urgent: true}, false},
+		{"json without full_text", `{"color": "#00FF00"}`, "json", nil, false},
+		{"invalid json", `{"full_text": `, "json", nil, true},
+		{"json of text", "hello", "json", nil, true},
+	}
+	for _, test := range tests {
+		got, err := parseOutput([]byte(test.stdout), test.output)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: parseOutput = %+v, want an error", test.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: parseOutput failed: %s", test.name, err)
+		} else if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: parseOutput = %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
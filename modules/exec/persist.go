@@ -0,0 +1,168 @@
+package exec
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/andir/go3status/modules"
+)
+
+// In persist mode the command is started once and every line it prints
+// replaces the block, like the persist interval of i3blocks. Clicks are
+// written to its stdin as JSON, one per line. A command that exits is
+// restarted with an exponential backoff.
+
+const (
+	minRestartDelay = time.Second
+	maxRestartDelay = 5 * time.Minute
+	// a command running at least this long starts over with the shortest
+	// delay once it exits
+	stableRuntime = time.Minute
+)
+
+type persistent struct {
+	sync.Mutex
+	stdin *os.File
+	last  *modules.Block
+	err   error
+}
+
+func (p *persistent) lastBlock() (*modules.Block, error) {
+	p.Lock()
+	defer p.Unlock()
+	if p.last == nil {
+		return nil, p.err
+	}
+	block := *p.last
+	return &block, p.err
+}
+
+func (p *persistent) set(block *modules.Block, err error) {
+	p.Lock()
+	p.last, p.err = block, err
+	p.Unlock()
+}
+
+// how long writing a click to the command may block
+const clickWriteTimeout = 100 * time.Millisecond
+
+func (p *persistent) sendClick(event modules.ClickEvent) {
+	p.Lock()
+	stdin := p.stdin
+	p.Unlock()
+	if stdin == nil {
+		return
+	}
+
+	// a command that doesn't read its stdin must not block the worker once
+	// the pipe is full, the click is dropped then
+	stdin.SetWriteDeadline(time.Now().Add(clickWriteTimeout))
+	line, _ := json.Marshal(event)
+	if _, err := stdin.Write(append(line, '\n')); err != nil {
+		log.Warning("Failed to pass click to the command: " + err.Error())
+	}
+}
+
+// Watch keeps the command running until done is closed.
+func (t *ExecInstance) Watch(update modules.UpdateFunc, refresh modules.RefreshFunc, done <-chan struct{}) {
+	if !t.config.Persist {
+		return
+	}
+
+	publish := func(block *modules.Block, err error) {
+		t.set(block, err)
+		update(t.lastBlock())
+	}
+
+	delay := minRestartDelay
+	for {
+		started := time.Now()
+		err := t.runPersistent(publish, done)
+
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		if time.Since(started) >= stableRuntime {
+			delay = minRestartDelay
+		}
+		if err == nil {
+			err = errors.New("command exited")
+		}
+		log.Warning(t.name + ": " + err.Error() + ", restarting in " + delay.String())
+		publish(nil, err)
+
+		select {
+		case <-done:
+			return
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > maxRestartDelay {
+			delay = maxRestartDelay
+		}
+	}
+}
+
+// runPersistent runs the command once and publishes every line of its
+// output until it exits or done is closed.
+func (t *ExecInstance) runPersistent(publish func(*modules.Block, error), done <-chan struct{}) (err error) {
+	cmd := t.command(t.environment())
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return
+	}
+	// unlike StdinPipe an os.Pipe supports write deadlines
+	stdinReader, stdin, err := os.Pipe()
+	if err != nil {
+		return
+	}
+	cmd.Stdin = stdinReader
+	err = cmd.Start()
+	stdinReader.Close()
+	if err != nil {
+		stdin.Close()
+		return
+	}
+
+	t.Lock()
+	t.stdin = stdin
+	t.Unlock()
+	defer func() {
+		t.Lock()
+		t.stdin = nil
+		t.Unlock()
+		stdin.Close()
+	}()
+
+	stopped := make(chan struct{})
+	defer close(stopped)
+	go func() {
+		select {
+		case <-done:
+			killGroup(cmd)
+		case <-stopped:
+		}
+	}()
+
+	output := t.config.Output
+	if output == "i3blocks" {
+		// every line is a new full_text
+		output = "text"
+	}
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		block, err := parseOutput(scanner.Bytes(), output)
+		if block != nil {
+			block.Name = t.name
+		}
+		publish(block, err)
+	}
+
+	return cmd.Wait()
+}
@@ -0,0 +1,262 @@
+package modules
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	humanize "github.com/dustin/go-humanize"
+)
+
+// toFloat converts the numbers and numeric strings templates get to work
+// with.
+func toFloat(value interface{}) (float64, error) {
+	if d, ok := value.(time.Duration); ok {
+		return d.Seconds(), nil
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	case reflect.String:
+		return strconv.ParseFloat(strings.TrimSpace(v.String()), 64)
+	}
+	return 0, fmt.Errorf("not a number: %v", value)
+}
+
+// bytes formats a size like 1.5 GiB.
+func bytes(value interface{}) (string, error) {
+	f, err := toFloat(value)
+	if err != nil || f < 0 {
+		return fmt.Sprint(value), err
+	}
+	return humanize.IBytes(uint64(f)), nil
+}
+
+// si formats a value with an SI prefix, e.g. {{ si "B/s" .Rate }} gives
+// 1.2 MB/s.
+func si(unit string, value interface{}) (string, error) {
+	f, err := toFloat(value)
+	if err != nil {
+		return "", err
+	}
+	return humanize.SIWithDigits(f, 1, unit), nil
+}
+
+// duration formats a duration or a number of seconds with its two largest
+// units, like 2h 5m.
+func duration(value interface{}) (string, error) {
+	var d time.Duration
+	switch value := value.(type) {
+	case time.Duration:
+		d = value
+	case string:
+		var err error
+		if d, err = time.ParseDuration(value); err != nil {
+			return "", err
+		}
+	default:
+		seconds, err := toFloat(value)
+		if err != nil {
+			return "", err
+		}
+		d = time.Duration(seconds * float64(time.Second))
+	}
+
+	sign := ""
+	if d < 0 {
+		sign, d = "-", -d
+	}
+	units := []struct {
+		suffix string
+		length time.Duration
+	}{
+		{"d", 24 * time.Hour},
+		{"h", time.Hour},
+		{"m", time.Minute},
+		{"s", time.Second},
+	}
+	parts := []string{}
+	for _, unit := range units {
+		if n := d / unit.length; n > 0 || (len(parts) == 0 && unit.suffix == "s") {
+			parts = append(parts, strconv.Itoa(int(n))+unit.suffix)
+			d -= n * unit.length
+		} else if len(parts) > 0 {
+			break
+		}
+		if len(parts) == 2 {
+			break
+		}
+	}
+	return sign + strings.Join(parts, " "), nil
+}
+
+// round rounds to the given number of decimal places.
+func round(places int, value interface{}) (float64, error) {
+	f, err := toFloat(value)
+	scale := math.Pow(10, float64(places))
+	return math.Round(f*scale) / scale, err
+}
+
+// pad aligns a value to the right of width characters, padRight to the
+// left.
+func pad(width int, value interface{}) string {
+	s := fmt.Sprint(value)
+	if n := width - utf8.RuneCountInString(s); n > 0 {
+		s = strings.Repeat(" ", n) + s
+	}
+	return s
+}
+
+func padRight(width int, value interface{}) string {
+	s := fmt.Sprint(value)
+	if n := width - utf8.RuneCountInString(s); n > 0 {
+		s += strings.Repeat(" ", n)
+	}
+	return s
+}
+
+// the eighths of a full block character, for bars
+var barParts = []rune(" ▏▎▍▌▋▊▉█")
+
+// bar draws a percentage as a bar of width characters.
+func bar(width int, percent interface{}) (string, error) {
+	f, err := toFloat(percent)
+	if err != nil {
+		return "", err
+	}
+	eighths := int(math.Round(math.Max(0, math.Min(100, f)) / 100 * float64(width*8)))
+	s := strings.Repeat("█", eighths/8)
+	if eighths%8 > 0 {
+		s += string(barParts[eighths%8])
+	}
+	return padRight(width, s), nil
+}
+
+// threshold picks the color of the palette for a value, like
+// {{ .Load1 | threshold 2 4 }}, see ThresholdLevel.
+func threshold(degraded, bad float64, value interface{}) (string, error) {
+	f, err := toFloat(value)
+	if err != nil {
+		return "", err
+	}
+	return CurrentPalette().Color(ThresholdLevel(f, degraded, bad)), nil
+}
+
+// truncate cuts a value after n characters, ellipsis marks the cut with …
+func truncate(n int, value interface{}) string {
+	s := fmt.Sprint(value)
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+func ellipsis(n int, value interface{}) string {
+	s := fmt.Sprint(value)
+	if utf8.RuneCountInString(s) <= n || n < 1 {
+		return s
+	}
+	return string([]rune(s)[:n-1]) + "…"
+}
+
+// fallback returns def if the value is empty, it is "default" in templates.
+func fallback(def interface{}, value interface{}) interface{} {
+	if value == nil {
+		return def
+	}
+	if v := reflect.ValueOf(value); v.IsZero() || (v.Kind() == reflect.Slice || v.Kind() == reflect.Map) && v.Len() == 0 {
+		return def
+	}
+	return value
+}
+
+// join joins the elements of a list with sep.
+func join(sep string, list interface{}) (string, error) {
+	v := reflect.ValueOf(list)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return "", fmt.Errorf("can't join %T", list)
+	}
+	parts := make([]string, v.Len())
+	for i := range parts {
+		parts[i] = fmt.Sprint(v.Index(i).Interface())
+	}
+	return strings.Join(parts, sep), nil
+}
+
+// date formats a time or a unix timestamp with a layout of the time
+// package, e.g. {{ now | date "15:04" }}.
+func date(layout string, value interface{}) (string, error) {
+	t, ok := value.(time.Time)
+	if !ok {
+		seconds, err := toFloat(value)
+		if err != nil {
+			return "", err
+		}
+		t = time.Unix(0, int64(seconds*float64(time.Second)))
+	}
+	return t.Format(layout), nil
+}
+
+// arithmetic applies an operation to two numbers.
+func arithmetic(op func(a, b float64) (float64, error)) func(a, b interface{}) (float64, error) {
+	return func(a, b interface{}) (float64, error) {
+		x, err := toFloat(a)
+		if err != nil {
+			return 0, err
+		}
+		y, err := toFloat(b)
+		if err != nil {
+			return 0, err
+		}
+		return op(x, y)
+	}
+}
+
+var errDivisionByZero = errors.New("division by zero")
+
+// templateFuncs are available in every format, modules can add their own
+// on top.
+var templateFuncs = template.FuncMap{
+	"bytes":     bytes,
+	"si":        si,
+	"duration":  duration,
+	"round":     round,
+	"pad":       pad,
+	"padRight":  padRight,
+	"bar":       bar,
+	"threshold": threshold,
+	"truncate":  truncate,
+	"ellipsis":  ellipsis,
+	"default":   fallback,
+	"join":      join,
+	"date":      date,
+	"now":       time.Now,
+	"add":       arithmetic(func(a, b float64) (float64, error) { return a + b, nil }),
+	"sub":       arithmetic(func(a, b float64) (float64, error) { return a - b, nil }),
+	"mul":       arithmetic(func(a, b float64) (float64, error) { return a * b, nil }),
+	"div": arithmetic(func(a, b float64) (float64, error) {
+		if b == 0 {
+			return 0, errDivisionByZero
+		}
+		return a / b, nil
+	}),
+	"mod": arithmetic(func(a, b float64) (float64, error) {
+		if b == 0 {
+			return 0, errDivisionByZero
+		}
+		return math.Mod(a, b), nil
+	}),
+	"min": arithmetic(func(a, b float64) (float64, error) { return math.Min(a, b), nil }),
+	"max": arithmetic(func(a, b float64) (float64, error) { return math.Max(a, b), nil }),
+}
@@ -2,41 +2,29 @@ package idlerpg
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
 	"encoding/xml"
+	"errors"
 	"github.com/andir/go3status/modules"
 	"github.com/op/go-logging"
 	"io/ioutil"
 	"net/http"
 	"text/template"
+	"time"
 )
 
 var log = logging.MustGetLogger("idlerpg")
 
-type IRPGItem struct {
-	Name   string `json:"name"`
-	Text   string `json:"full_text"`
-	Markup string `json:"markup"`
-}
-
-func (e IRPGItem) Marshal() (bytes []byte) {
-	var err error
-	if bytes, err = json.Marshal(e); err != nil {
-		log.Error(err.Error())
-	}
-	return
-}
-
 type IRPGInstance struct {
 	name        string
 	uri         string
 	player_name string
 	template    *template.Template
-	config      map[string]interface{}
+	config      *Config
 }
 
-func (i IRPGInstance) RefreshInterval() int {
-	return 900
+func (i IRPGInstance) RefreshInterval() time.Duration {
+	return 900 * time.Second
 }
 
 func (t IRPGInstance) Name() (n string) {
@@ -44,8 +32,8 @@ func (t IRPGInstance) Name() (n string) {
 	return
 }
 
-func (t IRPGInstance) Config() (m map[string]interface{}) {
-	m = t.config
+func (t IRPGInstance) Config() (c *Config) {
+	c = t.config
 	return
 }
 
@@ -94,74 +82,77 @@ type Player struct {
 	Items      Items     `xml:"items"`
 }
 
-func (t IRPGInstance) downloadData() (p *Player) {
+func (t IRPGInstance) downloadData(ctx context.Context) (p *Player, err error) {
 	player := new(Player)
 	log.Debug("Downloading " + t.uri)
-	if resp, err := http.Get(t.uri); err != nil {
-		//log.Fatal(err) // causes crashes
-		p = nil
+	req, err := http.NewRequestWithContext(ctx, "GET", t.uri, nil)
+	if err != nil {
+		return
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err = errors.New("unexpected response: " + resp.Status)
 		return
-	} else {
-		b, _ := ioutil.ReadAll(resp.Body)
-		xml.Unmarshal(b, &player)
-		p = player
 	}
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	if err = xml.Unmarshal(b, &player); err != nil {
+		return
+	}
+	p = player
 	return
 }
 
-func (t IRPGInstance) Render() (i modules.Item) {
+func (t IRPGInstance) Render(ctx context.Context) (b *modules.Block, err error) {
 
-	item := IRPGItem{Name: t.name, Markup: "pango"}
+	block := &modules.Block{Name: t.name, Markup: "pango"}
 
-	player := t.downloadData()
-	if player == nil {
+	player, err := t.downloadData(ctx)
+	if err != nil {
 		return
 	}
 
 	var formatted bytes.Buffer
-	t.template.Execute(&formatted, player)
+	if err = t.template.Execute(&formatted, player); err != nil {
+		return
+	}
 
-	item.Text = formatted.String()
+	block.Full_text = formatted.String()
 
-	i = modules.Item(item)
+	b = block
 	return
 }
 
-func CreateInstance(name string, config map[string]interface{}) (moduleInstance modules.ModuleInstance) {
-	i := IRPGInstance{
-		name: name,
-	}
-
-	var base_uri string
+const defaultFormat = "irpg {{ .Username }}: <span color=\"{{ if .Online}}green{{ else }}red{{end}}\">{{.Level}}</span>"
 
-	if v, ok := config["base_uri"]; ok {
-		base_uri = v.(string)
-	} else {
-		base_uri = "http://irpg.bspar.org/xml.php?player="
-	}
-
-	if v, ok := config["player"]; ok {
-		n := v.(string)
-		i.player_name = n
-	} else {
-		log.Error("Missing player name")
-		moduleInstance = nil
-		return
-	}
-	i.uri = base_uri + i.player_name
+type Config struct {
+	Base_uri string `config:"base_uri" default:"http://irpg.bspar.org/xml.php?player=" doc:"URI the player name is appended to"`
+	Player   string `config:"player" required:"true" doc:"name of the player"`
+	Format   string `config:"format" doc:"template rendered with the Player"`
+	Markup   string `block:"markup"`
+}
 
-	var format string
-	if v, ok := config["format"]; ok {
-		format = v.(string)
-	} else {
-		format = "irpg {{ .Username }}: <span color=\"{{ if .Online}}green{{ else }}red{{end}}\">{{.Level}}</span>"
+func CreateInstance(name string, config interface{}) (moduleInstance modules.ModuleInstance, err error) {
+	c := config.(*Config)
+	i := IRPGInstance{
+		name:        name,
+		player_name: c.Player,
+		config:      c,
 	}
+	i.uri = c.Base_uri + i.player_name
 
-	if template, err := template.New(i.name).Parse(format); err == nil {
+	if template, err := modules.NewTemplate(i.name, c.Format, c.Markup, nil); err == nil {
 		i.template = template
 	} else {
-		log.Error("Failed to create template: " + err.Error())
-		moduleInstance = nil
+		return nil, &modules.FieldError{Key: "format", Err: err}
 	}
 
 	moduleInstance = i
@@ -171,4 +162,7 @@ func CreateInstance(name string, config map[string]interface{}) (moduleInstance
 var Module = modules.Module{
 	Name:           "idlerpg",
 	CreateInstance: CreateInstance,
+	NewConfig: func() interface{} {
+		return &Config{Format: defaultFormat, Markup: "pango"}
+	},
 }
@@ -2,9 +2,11 @@ package load
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
 	"runtime"
 	"text/template"
+	"time"
 
 	"github.com/andir/go3status/modules"
 	"github.com/op/go-logging"
@@ -19,8 +21,8 @@ type LoadInstance struct {
 	template *template.Template
 }
 
-func (t LoadInstance) RefreshInterval() int {
-	return 5
+func (t LoadInstance) RefreshInterval() time.Duration {
+	return 5 * time.Second
 }
 
 func (t LoadInstance) Name() (n string) {
@@ -33,22 +35,8 @@ func (t LoadInstance) String() (s string) {
 	return
 }
 
-func (t LoadInstance) Render() (item modules.Item) {
-	item = RenderInstance(t)
-	return
-}
-
-type LoadItem struct {
-	Name   string `json:"name"`
-	Text   string `json:"full_text"`
-	Markup string `json:"markup"`
-}
-
-func (e LoadItem) Marshal() (bytes []byte) {
-	var err error
-	if bytes, err = json.Marshal(e); err != nil {
-		log.Error(err.Error())
-	}
+func (t LoadInstance) Render(ctx context.Context) (block *modules.Block, err error) {
+	block, err = RenderInstance(ctx, t)
 	return
 }
 
@@ -56,76 +44,70 @@ type RenderContext struct {
 	Load5, Load10, Load15 float32
 }
 
-func GetRenderContext() *load.AvgStat {
-	if avg, err := load.Avg(); err == nil {
-		return avg
-	} else {
-		log.Fatal(err)
-	}
-	return nil
+func GetRenderContext(ctx context.Context) (*load.AvgStat, error) {
+	return load.AvgWithContext(ctx)
 }
 
-func RenderInstance(i modules.ModuleInstance) (t modules.Item) {
+func RenderInstance(ctx context.Context, i modules.ModuleInstance) (b *modules.Block, err error) {
 
 	instance := i.(LoadInstance)
 	var formatted bytes.Buffer
 
-	renderContext := GetRenderContext()
+	renderContext, err := GetRenderContext(ctx)
+	if err != nil {
+		return
+	}
 
 	if instance.template == nil {
-		log.Error("template is nil")
+		err = errors.New("template is nil")
 		return
 	}
 
-	if err := instance.template.Execute(&formatted, renderContext); err != nil {
-		log.Fatal(err)
+	if err = instance.template.Execute(&formatted, renderContext); err != nil {
+		return
 	}
 
 	f := formatted.String()
 	log.Debug(f)
-	t = modules.Item(LoadItem{Name: instance.name, Text: f, Markup: "pango"})
+	b = &modules.Block{Name: instance.name, Full_text: f, Markup: "pango", Metrics: map[string]float64{
+		"load1":  renderContext.Load1,
+		"load5":  renderContext.Load5,
+		"load15": renderContext.Load15,
+	}}
 
 	return
 }
 
+// color picks the color of the palette for a load average, it is degraded
+// once there is more than one runnable process per CPU and bad from two on.
 func color(value float64) string {
-	numprocs := float64(runtime.NumCPU()) / 2
-	switch {
-	case value < 0.5*numprocs:
-		return "grey"
-	case value >= 0.5*numprocs && value < 1.5*numprocs:
-		return "#D9FF00"
-	case value >= 1.5*numprocs && value < 2*numprocs:
-		return "yellow"
-	case value >= 2*numprocs && value < 4*numprocs:
-		return "orange"
-	case value >= 4*numprocs:
-		return "red"
-	}
-	return ""
+	numprocs := float64(runtime.NumCPU())
+	return modules.CurrentPalette().Color(modules.ThresholdLevel(value, numprocs, 2*numprocs))
 }
 
-func CreateInstance(name string, config map[string]interface{}) (m modules.ModuleInstance) {
+const defaultFormat = `<span color="{{ color .Load1 }}">{{ .Load1 | printf "%2.2f" }}</span> <span color="{{ color .Load5 }}">{{.Load5 | printf "%2.2f"}}</span> <span color="{{ color .Load15 }}">{{.Load15 | printf "%2.2f"}}</span>`
 
-	var format string
+type Config struct {
+	Format string `config:"format" doc:"template rendered with the load averages"`
+	Markup string `block:"markup"`
+}
 
-	if i, ok := config["format"]; ok {
-		format = i.(string)
-	} else {
-		format = `<span color="{{ color .Load1 }}">{{ .Load1 | printf "%2.2f" }}</span> <span color="{{ color .Load5 }}">{{.Load5 | printf "%2.2f"}}</span> <span color="{{ color .Load15 }}">{{.Load15 | printf "%2.2f"}}</span>`
-	}
+func CreateInstance(name string, config interface{}) (m modules.ModuleInstance, err error) {
+
+	c := config.(*Config)
+	format := c.Format
 
 	f := LoadInstance{
 		name:   name,
 		format: format,
 	}
 
-	if template, err := template.New(name).Funcs(template.FuncMap{
+	if template, err := modules.NewTemplate(name, format, c.Markup, template.FuncMap{
 		"color": color,
-	}).Parse(format); err == nil {
+	}); err == nil {
 		f.template = template
 	} else {
-		log.Error("failed to create template: " + err.Error())
+		return nil, &modules.FieldError{Key: "format", Err: err}
 	}
 	m = modules.ModuleInstance(f)
 
@@ -135,4 +117,8 @@ func CreateInstance(name string, config map[string]interface{}) (m modules.Modul
 var Module = modules.Module{
 	Name:           "load",
 	CreateInstance: CreateInstance,
+	Metrics:        []string{"load1", "load5", "load15"},
+	NewConfig: func() interface{} {
+		return &Config{Format: defaultFormat, Markup: "pango"}
+	},
 }
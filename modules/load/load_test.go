@@ -0,0 +1,39 @@
+package load
+
+import (
+	"bytes"
+	"regexp"
+	"runtime"
+	"testing"
+
+	"github.com/andir/go3status/modules"
+	load "github.com/shirou/gopsutil/load"
+)
+
+func TestDefaultFormat(t *testing.T) {
+	defer modules.SetPalette(modules.CurrentPalette())
+	modules.SetPalette(modules.Palette{Good: "good", Degraded: "degraded", Bad: "bad"})
+
+	instance, err := CreateInstance("load", Module.NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cpus := float64(runtime.NumCPU())
+	var text bytes.Buffer
+	stat := &load.AvgStat{Load1: 0, Load5: cpus, Load15: 2 * cpus}
+	if err := instance.(LoadInstance).template.Execute(&text, stat); err != nil {
+		t.Fatal(err)
+	}
+
+	// every average is colored by itself
+	colors := regexp.MustCompile(`color="([a-z]*)"`).FindAllStringSubmatch(text.String(), -1)
+	want := []string{"good", "degraded", "bad"}
+	if len(colors) != len(want) {
+		t.Fatalf("%q has %d colored averages, want %d", text.String(), len(colors), len(want))
+	}
+	for i, color := range want {
+		if colors[i][1] != color {
+			t.Errorf("%q: average %d is %s, want %s", text.String(), i+1, colors[i][1], color)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package modules
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"text/template"
+)
+
+// Markup is text that already is valid pango markup, it is not escaped
+// again.
+type Markup string
+
+// EscapeMarkup escapes a value for pango markup unless it is Markup
+// already.
+func EscapeMarkup(value interface{}) Markup {
+	if markup, ok := value.(Markup); ok {
+		return markup
+	}
+	return Markup(html.EscapeString(fmt.Sprint(value)))
+}
+
+// the attributes span accepts, font is short for font_desc
+var spanAttributes = map[string]bool{
+	"color":         true,
+	"foreground":    true,
+	"background":    true,
+	"weight":        true,
+	"size":          true,
+	"font":          true,
+	"font_desc":     true,
+	"style":         true,
+	"underline":     true,
+	"strikethrough": true,
+	"rise":          true,
+}
+
+// span wraps text in a <span>, the arguments are pairs of attribute name
+// and value followed by the text:
+//
+//	{{ span "color" "red" "weight" "bold" .Title }}
+func span(args ...interface{}) (markup Markup, err error) {
+	if len(args)%2 != 1 {
+		err = errors.New("span wants pairs of attribute and value followed by the text")
+		return
+	}
+	var attributes strings.Builder
+	for i := 0; i < len(args)-1; i += 2 {
+		name := fmt.Sprint(args[i])
+		if !spanAttributes[name] {
+			err = errors.New("unknown span attribute: " + name)
+			return
+		}
+		attributes.WriteString(" " + name + `="` + html.EscapeString(fmt.Sprint(args[i+1])) + `"`)
+	}
+	markup = "<span" + Markup(attributes.String()) + ">" + EscapeMarkup(args[len(args)-1]) + "</span>"
+	return
+}
+
+func bold(text interface{}) Markup {
+	return "<b>" + EscapeMarkup(text) + "</b>"
+}
+
+func italic(text interface{}) Markup {
+	return "<i>" + EscapeMarkup(text) + "</i>"
+}
+
+// name of the function escaping the output of every action, see
+// NewTemplate
+const autoEscape = "_escape"
+
+// markupFuncs are the template functions for building pango markup.
+var markupFuncs = template.FuncMap{
+	"escape":   EscapeMarkup,
+	"span":     span,
+	"bold":     bold,
+	"italic":   italic,
+	autoEscape: EscapeMarkup,
+}
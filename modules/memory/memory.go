@@ -1,25 +1,27 @@
 package load
 
 import (
-	"encoding/json"
+	"bytes"
+	"context"
+	"errors"
 	"github.com/andir/go3status/modules"
+	humanize "github.com/dustin/go-humanize"
 	"github.com/op/go-logging"
 	mem "github.com/shirou/gopsutil/mem"
-	humanize "github.com/dustin/go-humanize"
 	"text/template"
-	"bytes"
+	"time"
 )
 
 var log = logging.MustGetLogger("go3status.memory")
 
 type MemoryInstance struct {
-	name   string
-	format string
+	name     string
+	format   string
 	template *template.Template
 }
 
-func (t MemoryInstance) RefreshInterval() int {
-	return 5
+func (t MemoryInstance) RefreshInterval() time.Duration {
+	return 5 * time.Second
 }
 
 func (t MemoryInstance) Name() (n string) {
@@ -32,22 +34,8 @@ func (t MemoryInstance) String() (s string) {
 	return
 }
 
-func (t MemoryInstance) Render() (item modules.Item) {
-	item = RenderInstance(t)
-	return
-}
-
-type LoadItem struct {
-	Name string `json:"name"`
-	Text string `json:"full_text"`
-	Markup string `json:"markup"`
-}
-
-func (e LoadItem) Marshal() (bytes []byte) {
-	var err error
-	if bytes, err = json.Marshal(e); err != nil {
-		log.Error(err.Error())
-	}
+func (t MemoryInstance) Render(ctx context.Context) (block *modules.Block, err error) {
+	block, err = RenderInstance(ctx, t)
 	return
 }
 
@@ -55,35 +43,34 @@ type RenderContext struct {
 	Load5, Load10, Load15 float32
 }
 
-
-func GetRenderContext() *mem.VirtualMemoryStat {
-	if avg, err := mem.VirtualMemory(); err == nil {
-		return avg
-	} else {
-		log.Fatal(err)
-	}
-	return nil
+func GetRenderContext(ctx context.Context) (*mem.VirtualMemoryStat, error) {
+	return mem.VirtualMemoryWithContext(ctx)
 }
 
-func RenderInstance(i modules.ModuleInstance) (t modules.Item) {
+func RenderInstance(ctx context.Context, i modules.ModuleInstance) (b *modules.Block, err error) {
 
 	instance := i.(MemoryInstance)
 	var formatted bytes.Buffer
 
-	renderContext := GetRenderContext()
+	renderContext, err := GetRenderContext(ctx)
+	if err != nil {
+		return
+	}
 
 	if instance.template == nil {
-		log.Error("template is nil")
+		err = errors.New("template is nil")
 		return
 	}
 
-	if err := instance.template.Execute(&formatted, renderContext); err != nil {
-		log.Fatal(err)
+	if err = instance.template.Execute(&formatted, renderContext); err != nil {
+		return
 	}
 
 	f := formatted.String()
 	log.Debug(f)
-	t = modules.Item(LoadItem{Name: instance.name, Text: f, Markup:"pango"})
+	b = &modules.Block{Name: instance.name, Full_text: f, Markup: "pango", Metrics: map[string]float64{
+		"used_percent": renderContext.UsedPercent,
+	}}
 
 	return
 }
@@ -93,15 +80,17 @@ func convert(value uint64) string {
 	return humanize.IBytes(value)
 }
 
-func CreateInstance(name string, config map[string]interface{}) (m modules.ModuleInstance) {
+const defaultFormat = `Memory: {{ printf "%3.2f %%" .UsedPercent }} ({{ convert .Used }} / {{ convert .Total }})`
 
-	var format string
+type Config struct {
+	Format string `config:"format" doc:"template rendered with the memory usage"`
+	Markup string `block:"markup"`
+}
 
-	if i, ok := config["format"]; ok {
-		format = i.(string)
-	} else {
-		format = `Memory: {{ printf "%3.2f %%" .UsedPercent }} ({{ convert .Used }} / {{ convert .Total }})`
-	}
+func CreateInstance(name string, config interface{}) (m modules.ModuleInstance, err error) {
+
+	c := config.(*Config)
+	format := c.Format
 
 	f := MemoryInstance{
 		name:   name,
@@ -112,10 +101,10 @@ func CreateInstance(name string, config map[string]interface{}) (m modules.Modul
 		"convert": convert,
 	}
 
-	if template, err := template.New(name).Funcs(funcMap).Parse(format); err == nil {
+	if template, err := modules.NewTemplate(name, format, c.Markup, funcMap); err == nil {
 		f.template = template
 	} else {
-		log.Error("failed to create template: " + err.Error())
+		return nil, &modules.FieldError{Key: "format", Err: err}
 	}
 	m = modules.ModuleInstance(f)
 
@@ -123,6 +112,10 @@ func CreateInstance(name string, config map[string]interface{}) (m modules.Modul
 }
 
 var Module = modules.Module{
-	Name:           "load",
+	Name:           "memory",
 	CreateInstance: CreateInstance,
+	Metrics:        []string{"used_percent"},
+	NewConfig: func() interface{} {
+		return &Config{Format: defaultFormat, Markup: "pango"}
+	},
 }
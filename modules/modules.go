@@ -1,21 +1,96 @@
 package modules
 
-type CreateInstanceFunc func(name string, config map[string]interface{}) ModuleInstance
-type RenderInstanceFunc func(instance ModuleInstance) (item Item, err error)
+import (
+	"context"
+	"time"
+
+	"github.com/op/go-logging"
+)
+
+var log = logging.MustGetLogger("go3status.modules")
+
+// CreateInstanceFunc creates an instance from the config struct returned by
+// NewConfig, decoded from the config of the block.
+type CreateInstanceFunc func(name string, config interface{}) (ModuleInstance, error)
+type RenderInstanceFunc func(ctx context.Context, instance ModuleInstance) (block *Block, err error)
 
 type Module struct {
 	Name           string
 	CreateInstance CreateInstanceFunc
 	RenderInstance RenderInstanceFunc
+	// NewConfig returns a pointer to the config struct of the module, see
+	// DecodeConfig
+	NewConfig func() interface{}
+	// Metrics names the values the module reports in Block.Metrics
+	Metrics []string
 }
 
-type Item interface {
-	Marshal() []byte
+// Schema describes the module specific config keys.
+func (m Module) Schema() Schema {
+	if m.NewConfig == nil {
+		return Schema{}
+	}
+	return SchemaOf(m.NewConfig())
+}
+
+// Configure decodes the config of a block and creates the instance.
+func (m Module) Configure(name string, raw map[string]interface{}) (ModuleInstance, error) {
+	var config interface{} = &struct{}{}
+	if m.NewConfig != nil {
+		config = m.NewConfig()
+	}
+	if err := DecodeConfig(raw, config); err != nil {
+		return nil, err
+	}
+	return m.CreateInstance(name, config)
 }
 
 type ModuleInstance interface {
 	Name() string
 	String() string
-	Render() Item
-	RefreshInterval() int
+	// Render returns the current block of the instance. A nil block without
+	// an error hides the block. Instances doing I/O should give up once ctx
+	// is done.
+	Render(ctx context.Context) (*Block, error)
+	// RefreshInterval is the default time between two renders, it can be
+	// overridden by the interval setting of the instance.
+	RefreshInterval() time.Duration
+}
+
+// ClickEvent is a single click on a block as reported by i3bar on stdin.
+type ClickEvent struct {
+	Name       string   `json:"name"`
+	Instance   string   `json:"instance"`
+	Button     int      `json:"button"`
+	Modifiers  []string `json:"modifiers"`
+	X          int      `json:"x"`
+	Y          int      `json:"y"`
+	Relative_x int      `json:"relative_x"`
+	Relative_y int      `json:"relative_y"`
+	Width      int      `json:"width"`
+	Height     int      `json:"height"`
+}
+
+// ClickHandler can optionally be implemented by a ModuleInstance that wants
+// to react to clicks on its block. The block is re-rendered right after
+// Click returns.
+type ClickHandler interface {
+	Click(event ClickEvent)
+}
+
+// UpdateFunc publishes a freshly rendered block of an instance, with the
+// same semantics as the return values of Render.
+type UpdateFunc func(block *Block, err error)
+
+// RefreshFunc asks for the block to be rendered right away. The render runs
+// like a scheduled one, within the timeout of the instance.
+type RefreshFunc func()
+
+// Updater can optionally be implemented by a ModuleInstance that knows when
+// its data changes. Watch is run on its own goroutine and returns once done
+// is closed. Whenever something happened it either publishes a block
+// through update or, if rendering may block, calls refresh. Polling through
+// Render continues as a fallback.
+type Updater interface {
+	Watch(update UpdateFunc, refresh RefreshFunc, done <-chan struct{})
 }
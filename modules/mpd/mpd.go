@@ -2,7 +2,8 @@ package mpd
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
 	"github.com/andir/go3status/modules"
 	go_mpd "github.com/fhs/gompd/mpd"
 	"github.com/op/go-logging"
@@ -10,24 +11,11 @@ import (
 	"strconv"
 	"strings"
 	"text/template"
+	"time"
 )
 
 var log = logging.MustGetLogger("go3status.mpd")
 
-type MPDItem struct {
-	Name   string `json:"name"`
-	Text   string `json:"full_text"`
-	Markup string `json:"markup"`
-}
-
-func (e MPDItem) Marshal() (bytes []byte) {
-	var err error
-	if bytes, err = json.Marshal(e); err != nil {
-		log.Error(err.Error())
-	}
-	return
-}
-
 type MPDInstance struct {
 	name      string
 	host_name string
@@ -35,8 +23,8 @@ type MPDInstance struct {
 	template  *template.Template
 }
 
-func (m MPDInstance) RefreshInterval() int {
-	return 5
+func (m MPDInstance) RefreshInterval() time.Duration {
+	return 5 * time.Second
 }
 
 func (m MPDInstance) Name() string {
@@ -54,6 +42,74 @@ func (m MPDInstance) String() (s string) {
 	return
 }
 
+func (m MPDInstance) address() string {
+	return m.host_name + ":" + strconv.Itoa(m.port)
+}
+
+// Watch uses the idle protocol of MPD to re-render the block as soon as the
+// player state changes. Lost connections are retried every
+// RefreshInterval.
+func (m MPDInstance) Watch(update modules.UpdateFunc, refresh modules.RefreshFunc, done <-chan struct{}) {
+	retry := m.RefreshInterval()
+	for {
+		watcher, err := go_mpd.NewWatcher("tcp", m.address(), "", "player", "mixer", "options")
+		if err != nil {
+			log.Error("Failed to watch mpd: " + err.Error())
+		} else {
+			m.watch(watcher, refresh, done)
+			watcher.Close()
+		}
+
+		select {
+		case <-done:
+			return
+		case <-time.After(retry):
+		}
+	}
+}
+
+func (m MPDInstance) watch(watcher *go_mpd.Watcher, refresh modules.RefreshFunc, done <-chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		case subsystem := <-watcher.Event:
+			log.Debug("mpd event: " + subsystem)
+			refresh()
+		case err := <-watcher.Error:
+			log.Error("mpd watcher failed: " + err.Error())
+			return
+		}
+	}
+}
+
+type dialResult struct {
+	client *go_mpd.Client
+	err    error
+}
+
+// dial connects to MPD unless ctx is done first. gompd doesn't know about
+// contexts, a connection that is established too late is closed again.
+func (m MPDInstance) dial(ctx context.Context) (*go_mpd.Client, error) {
+	result := make(chan dialResult, 1)
+	go func() {
+		client, err := go_mpd.Dial("tcp", m.address())
+		result <- dialResult{client, err}
+	}()
+
+	select {
+	case r := <-result:
+		return r.client, r.err
+	case <-ctx.Done():
+		go func() {
+			if r := <-result; r.client != nil {
+				r.client.Close()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
 type MPDFormatData struct {
 	Artist   string
 	Song     string
@@ -69,16 +125,13 @@ type MPDFormatData struct {
 	State    string
 }
 
-func (m MPDInstance) Render() (item modules.Item) {
-	mpdItem := MPDItem{Name: m.name, Markup: "pango"}
+func (m MPDInstance) Render(ctx context.Context) (block *modules.Block, err error) {
+	mpdBlock := &modules.Block{Name: m.name, Markup: "pango"}
 	mpdFormatData := MPDFormatData{}
 	var client *go_mpd.Client
 
-	if c, err := go_mpd.Dial("tcp", m.host_name+":"+strconv.Itoa(m.port)); err != nil {
-		log.Error(err.Error())
-		return nil
-	} else {
-		client = c
+	if client, err = m.dial(ctx); err != nil {
+		return
 	}
 
 	defer func(client *go_mpd.Client) {
@@ -87,21 +140,21 @@ func (m MPDInstance) Render() (item modules.Item) {
 		}
 	}(client)
 
-	if attrs, err := client.Status(); err == nil {
+	if attrs, e := client.Status(); e == nil {
 		if state, ok := attrs["state"]; ok {
 			mpdFormatData.State = state
 		} else {
-			log.Error("Failed to read state.")
-			return nil
+			err = errors.New("failed to read state")
+			return
 		}
 	} else {
-		log.Error("Failed to obtain status: " + err.Error())
-		return nil
+		err = errors.New("failed to obtain status: " + e.Error())
+		return
 	}
 
-	if attrs, err := client.CurrentSong(); err != nil {
-		log.Error("Failed to obtain current song: " + err.Error())
-		return nil
+	if attrs, e := client.CurrentSong(); e != nil {
+		err = errors.New("failed to obtain current song: " + e.Error())
+		return
 	} else {
 		obj := reflect.ValueOf(&mpdFormatData).Elem()
 		for key, val := range attrs {
@@ -114,44 +167,37 @@ func (m MPDInstance) Render() (item modules.Item) {
 
 	buffer := bytes.Buffer{}
 
-	if err := m.template.Execute(&buffer, mpdFormatData); err != nil {
-		log.Error("Failed to render mpd template: " + err.Error())
-		return nil
+	if err = m.template.Execute(&buffer, mpdFormatData); err != nil {
+		return
 	} else {
-		mpdItem.Text = buffer.String()
+		mpdBlock.Full_text = buffer.String()
 	}
 
-	item = mpdItem
+	block = mpdBlock
 	return
 }
 
-func CreateInstance(name string, config map[string]interface{}) (instance modules.ModuleInstance) {
-	mpdInstance := MPDInstance{name: name}
+const defaultFormat = "[{{.State}}] {{ .Artist }} - {{ .Title }}"
 
-	if v, ok := config["host_name"]; ok {
-		mpdInstance.host_name = v.(string)
-	} else {
-		mpdInstance.host_name = "127.0.0.1"
-	}
+type Config struct {
+	Host_name string `config:"host_name" default:"127.0.0.1" doc:"host MPD is running on"`
+	Port      int    `config:"port" default:"6600" doc:"port MPD is listening on"`
+	Format    string `config:"format" doc:"template rendered with the MPDFormatData"`
+	Markup    string `block:"markup"`
+}
 
-	if v, ok := config["port"]; ok {
-		mpdInstance.port = v.(int)
-	} else {
-		mpdInstance.port = 6600
-	}
-	var format string
-	if v, ok := config["format"]; ok {
-		format = v.(string)
-	} else {
-		format = "[{{.Status}}] {{ .Artist }} - {{ .Song }}"
+func CreateInstance(name string, config interface{}) (instance modules.ModuleInstance, err error) {
+	c := config.(*Config)
+	mpdInstance := MPDInstance{
+		name:      name,
+		host_name: c.Host_name,
+		port:      c.Port,
 	}
 
-	if tmpl, err := template.New(mpdInstance.name).Parse(format); err == nil {
+	if tmpl, err := modules.NewTemplate(mpdInstance.name, c.Format, c.Markup, nil); err == nil {
 		mpdInstance.template = tmpl
 	} else {
-		log.Error("Failed to parse template:" + format)
-		instance = nil
-		return
+		return nil, &modules.FieldError{Key: "format", Err: err}
 	}
 
 	instance = modules.ModuleInstance(mpdInstance)
@@ -161,4 +207,7 @@ func CreateInstance(name string, config map[string]interface{}) (instance module
 var Module = modules.Module{
 	Name:           "mpd",
 	CreateInstance: CreateInstance,
+	NewConfig: func() interface{} {
+		return &Config{Format: defaultFormat, Markup: "pango"}
+	},
 }
@@ -2,39 +2,27 @@ package net
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
 	"github.com/andir/go3status/modules"
 	"github.com/op/go-logging"
 	go_net "net"
 	"text/template"
+	"time"
 )
 
 var log = logging.MustGetLogger("go3status.net")
 
-type NetItem struct {
-	Name   string `json:"name"`
-	Text   string `json:"full_text"`
-	Markup string `json:"markup"`
-}
-
-func (e NetItem) Marshal() (bytes []byte) {
-	var err error
-	if bytes, err = json.Marshal(e); err != nil {
-		log.Error(err.Error())
-	}
-	return
-}
-
 type NetInstance struct {
 	name           string
 	interface_name string
 	template       *template.Template
-//	config         map[string]interface{}
-	ignore_local     bool
+	//	config         map[string]interface{}
+	ignore_local bool
 }
 
-func (t NetInstance) RefreshInterval() int {
-	return 5
+func (t NetInstance) RefreshInterval() time.Duration {
+	return 5 * time.Second
 }
 
 func (t NetInstance) Name() (n string) {
@@ -60,16 +48,16 @@ type NetFormatData struct {
 	Addresses      []string
 }
 
-func (t NetInstance) Render() (i modules.Item) {
+func (t NetInstance) Render(ctx context.Context) (b *modules.Block, err error) {
 
 	if t.template == nil {
-		log.Error("No template available.")
+		err = errors.New("no template available")
 		return
 	}
 	_, linkLocalv6, _ := go_net.ParseCIDR("fe80::/10")
 	_, privatev6, _ := go_net.ParseCIDR("fd00::/8")
 
-	item := NetItem{Name: t.name, Markup: "pango"}
+	block := &modules.Block{Name: t.name, Markup: "pango"}
 
 	interface_name := t.interface_name
 
@@ -91,46 +79,36 @@ func (t NetInstance) Render() (i modules.Item) {
 	}
 
 	var formatted bytes.Buffer
-	t.template.Execute(&formatted, formatData)
-	item.Text = formatted.String()
+	if err = t.template.Execute(&formatted, formatData); err != nil {
+		return
+	}
+	block.Full_text = formatted.String()
 
-	i = modules.Item(item)
+	b = block
 	return
 }
 
-func CreateInstance(name string, config map[string]interface{}) (moduleInstance modules.ModuleInstance) {
-	i := NetInstance{
-		name: name,
-		ignore_local: true,
-	}
+const defaultFormat = "{{.Interface_name}}: {{range $i, $v := .Addresses}}{{if $i}}, {{end}}{{$v}}{{end}}"
 
-	if v, ok := config["interface_name"]; ok {
-		interface_name := v.(string)
-		i.interface_name = interface_name
-
-	} else {
-		log.Error("Missing interface_name in " + name)
-		moduleInstance = nil
-		return
-	}
+type Config struct {
+	Interface_name string `config:"interface_name" required:"true" doc:"name of the network interface"`
+	Ignore_local   bool   `config:"ignore_local" default:"true" doc:"hide link local and private IPv6 addresses"`
+	Format         string `config:"format" doc:"template rendered with the NetFormatData"`
+	Markup         string `block:"markup"`
+}
 
-	if v, ok := config["ignore_local"]; ok {
-		val, okayish := v.(bool)
-		if okayish {
-			i.ignore_local = val
-		}
+func CreateInstance(name string, config interface{}) (moduleInstance modules.ModuleInstance, err error) {
+	c := config.(*Config)
+	i := NetInstance{
+		name:           name,
+		interface_name: c.Interface_name,
+		ignore_local:   c.Ignore_local,
 	}
 
-	var format string
-	if v, ok := config["format"]; ok {
-		format = v.(string)
-	} else {
-		format = "{{.Interface_name}}: {{range $i, $v := .Addresses}}{{if $i}}, {{end}}{{$v}}{{end}}"
-	}
-	if template, err := template.New(i.name).Parse(format); err == nil {
+	if template, err := modules.NewTemplate(i.name, c.Format, c.Markup, nil); err == nil {
 		i.template = template
 	} else {
-		log.Error("Failed to create template: " + err.Error())
+		return nil, &modules.FieldError{Key: "format", Err: err}
 	}
 
 	moduleInstance = i
@@ -141,4 +119,7 @@ func CreateInstance(name string, config map[string]interface{}) (moduleInstance
 var Module = modules.Module{
 	Name:           "net",
 	CreateInstance: CreateInstance,
+	NewConfig: func() interface{} {
+		return &Config{Format: defaultFormat, Markup: "pango"}
+	},
 }
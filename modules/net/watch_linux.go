@@ -0,0 +1,28 @@
+package net
+
+import (
+	"syscall"
+
+	"github.com/andir/go3status/modules"
+)
+
+// multicast groups of rtnetlink, see linux/rtnetlink.h
+const (
+	rtmgrpLink       = 0x1
+	rtmgrpIPv4Ifaddr = 0x10
+	rtmgrpIPv6Ifaddr = 0x100
+)
+
+// Watch re-renders the block whenever the kernel reports link or address
+// changes.
+func (t NetInstance) Watch(update modules.UpdateFunc, refresh modules.RefreshFunc, done <-chan struct{}) {
+	groups := uint32(rtmgrpLink | rtmgrpIPv4Ifaddr | rtmgrpIPv6Ifaddr)
+
+	err := modules.WatchNetlink(syscall.NETLINK_ROUTE, groups, func(msg []byte) {
+		refresh()
+	}, done)
+
+	if err != nil {
+		log.Error("Failed to watch interface changes: " + err.Error())
+	}
+}
@@ -0,0 +1,44 @@
+package modules
+
+import (
+	"syscall"
+	"time"
+)
+
+// WatchNetlink subscribes to the given multicast groups of a netlink
+// protocol and calls handle with every message received until done is
+// closed.
+func WatchNetlink(protocol int, groups uint32, handle func(msg []byte), done <-chan struct{}) (err error) {
+	var fd int
+	if fd, err = syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, protocol); err != nil {
+		return
+	}
+	defer syscall.Close(fd)
+
+	if err = syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: groups}); err != nil {
+		return
+	}
+
+	// wake up regularly to notice when we should stop
+	timeout := syscall.NsecToTimeval(int64(time.Second))
+	if err = syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &timeout); err != nil {
+		return
+	}
+
+	buf := make([]byte, 16384)
+	for {
+		select {
+		case <-done:
+			return nil
+		default:
+		}
+
+		n, _, e := syscall.Recvfrom(fd, buf, 0)
+		if e == syscall.EAGAIN || e == syscall.EINTR {
+			continue
+		} else if e != nil {
+			return e
+		}
+		handle(buf[:n])
+	}
+}
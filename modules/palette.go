@@ -0,0 +1,72 @@
+package modules
+
+import "sync"
+
+// Level is how good a value is compared to its thresholds.
+type Level int
+
+const (
+	Good Level = iota
+	Degraded
+	Bad
+)
+
+// ThresholdLevel rates a value that gets worse when it reaches degraded
+// and bad. If bad is below degraded low values are the bad ones, as for a
+// battery.
+func ThresholdLevel(value, degraded, bad float64) Level {
+	if bad < degraded {
+		value, degraded, bad = -value, -degraded, -bad
+	}
+	switch {
+	case value >= bad:
+		return Bad
+	case value >= degraded:
+		return Degraded
+	}
+	return Good
+}
+
+// Palette holds the colors of the levels, shared by the whole bar.
+type Palette struct {
+	Good     string
+	Degraded string
+	Bad      string
+}
+
+var DefaultPalette = Palette{
+	Good:     "#00FF00",
+	Degraded: "#FFFF00",
+	Bad:      "#FF0000",
+}
+
+// Color returns the color of a level.
+func (p Palette) Color(level Level) string {
+	switch level {
+	case Degraded:
+		return p.Degraded
+	case Bad:
+		return p.Bad
+	}
+	return p.Good
+}
+
+var palette = struct {
+	sync.Mutex
+	Palette
+}{Palette: DefaultPalette}
+
+// SetPalette changes the colors used by thresholds and the threshold
+// template function.
+func SetPalette(p Palette) {
+	palette.Lock()
+	palette.Palette = p
+	palette.Unlock()
+}
+
+// CurrentPalette returns the colors set by SetPalette.
+func CurrentPalette() Palette {
+	palette.Lock()
+	defer palette.Unlock()
+	return palette.Palette
+}
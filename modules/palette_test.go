@@ -0,0 +1,59 @@
+package modules
+
+import "testing"
+
+func TestThresholdLevel(t *testing.T) {
+	tests := []struct {
+		value, degraded, bad float64
+		want                 Level
+	}{
+		{0, 70, 90, Good},
+		{69.9, 70, 90, Good},
+		{70, 70, 90, Degraded},
+		{89, 70, 90, Degraded},
+		{90, 70, 90, Bad},
+		{100, 70, 90, Bad},
+		{-1, 70, 90, Good},
+		// low values are bad, as for a battery
+		{100, 20, 10, Good},
+		{20.1, 20, 10, Good},
+		{20, 20, 10, Degraded},
+		{11, 20, 10, Degraded},
+		{10, 20, 10, Bad},
+		{0, 20, 10, Bad},
+		// without a degraded range
+		{4, 5, 5, Good},
+		{5, 5, 5, Bad},
+	}
+	for _, test := range tests {
+		if got := ThresholdLevel(test.value, test.degraded, test.bad); got != test.want {
+			t.Errorf("ThresholdLevel(%v, %v, %v) = %v, want %v", test.value, test.degraded, test.bad, got, test.want)
+		}
+	}
+}
+
+func TestThresholdFunc(t *testing.T) {
+	defer SetPalette(CurrentPalette())
+	SetPalette(Palette{Good: "good", Degraded: "degraded", Bad: "bad"})
+
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{1, "good"},
+		{2.0, "degraded"},
+		{float32(4), "bad"},
+		{"3", "degraded"},
+	}
+	for _, test := range tests {
+		got, err := threshold(2, 4, test.value)
+		if err != nil {
+			t.Errorf("threshold(2, 4, %v) failed: %s", test.value, err)
+		} else if got != test.want {
+			t.Errorf("threshold(2, 4, %v) = %q, want %q", test.value, got, test.want)
+		}
+	}
+	if _, err := threshold(2, 4, "high"); err == nil {
+		t.Error("threshold(2, 4, \"high\") didn't fail")
+	}
+}
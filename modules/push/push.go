@@ -0,0 +1,331 @@
+package push
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	go_net "net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andir/go3status/modules"
+	"github.com/op/go-logging"
+)
+
+var log = logging.MustGetLogger("go3status.push")
+
+type Config struct {
+	Socket string        `config:"socket" doc:"path of a unix socket external writers connect to"`
+	Listen string        `config:"listen" doc:"localhost address of the HTTP endpoint, e.g. 127.0.0.1:7777"`
+	Text   string        `config:"text" doc:"text shown until something is pushed, the block is hidden if empty"`
+	Ttl    time.Duration `config:"ttl" doc:"how long pushed content is shown, forever if unset"`
+	Expire string        `config:"expire" default:"revert" allowed:"revert,hide" doc:"what happens once the ttl ran out, revert to text or hide the block"`
+}
+
+// message is what writers push, either as a JSON object or as a line of
+// plain text that becomes the full_text.
+type message struct {
+	modules.Block
+	// overrides the ttl of the config, e.g. "30s"
+	Ttl string `json:"ttl"`
+}
+
+type PushInstance struct {
+	name   string
+	config *Config
+
+	sync.Mutex
+	// the pushed block, nil until something is pushed
+	block   *modules.Block
+	expires time.Time
+	timer   *time.Timer
+	refresh modules.RefreshFunc
+}
+
+func (t *PushInstance) RefreshInterval() time.Duration {
+	// new content is pushed, this only catches up on missed expiries
+	return time.Minute
+}
+
+func (t *PushInstance) Name() (n string) {
+	n = t.name
+	return
+}
+
+func (t *PushInstance) String() (s string) {
+	s = t.Name()
+	return
+}
+
+func (t *PushInstance) Render(ctx context.Context) (block *modules.Block, err error) {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.block != nil && !t.expires.IsZero() && !time.Now().Before(t.expires) {
+		t.block = nil
+		t.expires = time.Time{}
+		if t.config.Expire == "hide" {
+			// an empty block hides the text of the config as well
+			t.block = &modules.Block{}
+		}
+	}
+
+	if t.block != nil {
+		b := *t.block
+		block = &b
+	} else if t.config.Text != "" {
+		block = &modules.Block{Full_text: t.config.Text}
+	}
+	if block != nil {
+		block.Name = t.name
+		if block.Full_text == "" {
+			block = nil
+		}
+	}
+	return
+}
+
+// push shows a message of a writer.
+func (t *PushInstance) push(text []byte) error {
+	var m message
+	text = []byte(strings.TrimSpace(string(text)))
+	if len(text) > 0 && text[0] == '{' {
+		if err := json.Unmarshal(text, &m); err != nil {
+			return err
+		}
+	} else {
+		m.Full_text = string(text)
+	}
+
+	ttl := t.config.Ttl
+	if m.Ttl != "" {
+		var err error
+		if ttl, err = time.ParseDuration(m.Ttl); err != nil {
+			return errors.New("invalid ttl: " + err.Error())
+		}
+	}
+
+	t.Lock()
+	t.block = &m.Block
+	t.expires = time.Time{}
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+	if ttl > 0 {
+		t.expires = time.Now().Add(ttl)
+		t.timer = time.AfterFunc(ttl, t.publish)
+	}
+	t.Unlock()
+
+	t.publish()
+	return nil
+}
+
+// clear removes the pushed content.
+func (t *PushInstance) clear() {
+	t.Lock()
+	t.block = nil
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+	t.Unlock()
+
+	t.publish()
+}
+
+// publish asks for the stored block to be rendered. It doesn't wait for the
+// render, which is held back while the bar is paused, so writers get their
+// reply right away and the latest push wins.
+func (t *PushInstance) publish() {
+	t.Lock()
+	refresh := t.refresh
+	t.Unlock()
+
+	if refresh != nil {
+		refresh()
+	}
+}
+
+// Watch serves the socket and the HTTP endpoint until done is closed.
+func (t *PushInstance) Watch(update modules.UpdateFunc, refresh modules.RefreshFunc, done <-chan struct{}) {
+	t.Lock()
+	t.refresh = refresh
+	t.Unlock()
+
+	var listeners []go_net.Listener
+	if t.config.Socket != "" {
+		if l, err := listen("unix", t.config.Socket, done); err == nil {
+			listeners = append(listeners, l)
+			go t.serveSocket(l)
+		} else {
+			log.Error(t.name + ": failed to listen on " + t.config.Socket + ": " + err.Error())
+		}
+	}
+	if t.config.Listen != "" {
+		if l, err := listen("tcp", t.config.Listen, done); err == nil {
+			listeners = append(listeners, l)
+			go http.Serve(l, t)
+		} else {
+			log.Error(t.name + ": failed to listen on " + t.config.Listen + ": " + err.Error())
+		}
+	}
+
+	<-done
+	for _, l := range listeners {
+		l.Close()
+	}
+
+	t.Lock()
+	t.refresh = nil
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+	t.Unlock()
+}
+
+// listen retries for a while, the instance replaced by a config reload
+// may still be holding the address.
+func listen(network string, address string, done <-chan struct{}) (listener go_net.Listener, err error) {
+	for attempt := 0; ; attempt++ {
+		if listener, err = tryListen(network, address); err == nil || attempt == 20 {
+			return
+		}
+		select {
+		case <-done:
+			return
+		case <-time.After(250 * time.Millisecond):
+		}
+	}
+}
+
+func tryListen(network string, address string) (go_net.Listener, error) {
+	if network == "unix" {
+		// a socket nobody answers on is left over from a crash
+		if conn, err := go_net.Dial("unix", address); err == nil {
+			conn.Close()
+			return nil, errors.New("address already in use")
+		}
+		os.Remove(address)
+	}
+	return go_net.Listen(network, address)
+}
+
+// serveSocket reads one message per line from every connection.
+func (t *PushInstance) serveSocket(l go_net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			scanner := bufio.NewScanner(conn)
+			for scanner.Scan() {
+				if err := t.push(scanner.Bytes()); err != nil {
+					log.Warning(t.name + ": invalid message: " + err.Error())
+					io.WriteString(conn, "error: "+err.Error()+"\n")
+				}
+			}
+		}()
+	}
+}
+
+// ServeHTTP sets the block on POST and PUT, clears it on DELETE and returns
+// the current block on GET.
+func (t *PushInstance) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := checkRequest(r); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost, http.MethodPut:
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, 64*1024))
+		if err == nil {
+			err = t.push(body)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	case http.MethodDelete:
+		t.clear()
+	case http.MethodGet:
+		block, _ := t.Render(r.Context())
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(block)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// header that lets a push through without a JSON content type, e.g.
+// curl -H "X-Go3status: 1" -d "text" localhost:7777
+const pushHeader = "X-Go3status"
+
+// checkRequest keeps web pages out, which can send requests to localhost as
+// well. Browsers always send an Origin for cross-site requests, and can't
+// send a JSON content type or a custom header without asking first. DNS
+// rebinding is stopped by the Host check.
+func checkRequest(r *http.Request) error {
+	host := r.Host
+	if h, _, err := go_net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+	if ip := go_net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return errors.New("host must be localhost")
+	}
+	if r.Header.Get("Origin") != "" {
+		return errors.New("requests from browsers aren't allowed")
+	}
+	if r.Method == http.MethodPost || r.Method == http.MethodPut {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if mediaType != "application/json" && r.Header.Get(pushHeader) == "" {
+			return errors.New("content type must be application/json, or set the " + pushHeader + " header")
+		}
+	}
+	return nil
+}
+
+// isLocal reports whether the HTTP endpoint is only reachable from this
+// machine.
+func isLocal(address string) bool {
+	host, _, err := go_net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := go_net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func CreateInstance(name string, config interface{}) (instance modules.ModuleInstance, err error) {
+	c := config.(*Config)
+	if c.Socket == "" && c.Listen == "" {
+		return nil, &modules.FieldError{Key: "socket", Err: errors.New("either socket or listen has to be set")}
+	}
+	if c.Listen != "" && !isLocal(c.Listen) {
+		return nil, &modules.FieldError{Key: "listen", Err: errors.New("has to be a localhost address like 127.0.0.1:7777")}
+	}
+
+	instance = &PushInstance{
+		name:   name,
+		config: c,
+	}
+	return
+}
+
+var Module = modules.Module{
+	Name:           "push",
+	CreateInstance: CreateInstance,
+	NewConfig:      func() interface{} { return &Config{} },
+}
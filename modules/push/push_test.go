@@ -0,0 +1,149 @@
+package push
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andir/go3status/modules"
+)
+
+func TestCheckRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		host    string
+		headers map[string]string
+		ok      bool
+	}{
+		{"json", "POST", "127.0.0.1:7777", map[string]string{"Content-Type": "application/json"}, true},
+		{"json with charset", "PUT", "localhost:7777", map[string]string{"Content-Type": "application/json; charset=utf-8"}, true},
+		{"header", "POST", "[::1]:7777", map[string]string{pushHeader: "1"}, true},
+		{"get", "GET", "localhost", nil, true},
+		{"delete", "DELETE", "127.0.0.1:7777", nil, true},
+		{"form", "POST", "127.0.0.1:7777", map[string]string{"Content-Type": "application/x-www-form-urlencoded"}, false},
+		{"no content type", "POST", "127.0.0.1:7777", nil, false},
+		{"origin", "POST", "127.0.0.1:7777", map[string]string{"Content-Type": "application/json", "Origin": "http://example.com"}, false},
+		{"origin on get", "GET", "127.0.0.1:7777", map[string]string{"Origin": "null"}, false},
+		{"rebound host", "POST", "evil.example.com:7777", map[string]string{"Content-Type": "application/json"}, false},
+		{"lan address", "GET", "192.168.1.2:7777", nil, false},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(test.method, "/", nil)
+		r.Host = test.host
+		for key, value := range test.headers {
+			r.Header.Set(key, value)
+		}
+		if err := checkRequest(r); (err == nil) != test.ok {
+			t.Errorf("%s: checkRequest() = %v, want ok %v", test.name, err, test.ok)
+		}
+	}
+}
+
+// watch runs Watch of the instance until the test ends. The update function
+// blocks like the one of a paused bar, refreshes are counted.
+func watch(t *testing.T, instance *PushInstance) <-chan struct{} {
+	done := make(chan struct{})
+	refreshed := make(chan struct{}, 16)
+	update := func(block *modules.Block, err error) {
+		<-done
+	}
+	refresh := func() {
+		refreshed <- struct{}{}
+	}
+	stopped := make(chan struct{})
+	go func() {
+		instance.Watch(update, refresh, done)
+		close(stopped)
+	}()
+	t.Cleanup(func() {
+		close(done)
+		<-stopped
+	})
+	return refreshed
+}
+
+func TestServeHTTP(t *testing.T) {
+	instance := &PushInstance{name: "push", config: &Config{Text: "idle", Expire: "revert"}}
+	refreshed := watch(t, instance)
+	// Watch has to be running before the push
+	for deadline := time.Now().Add(time.Second); ; {
+		instance.Lock()
+		running := instance.refresh != nil
+		instance.Unlock()
+		if running {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatal("Watch didn't start")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	serve := func(method, body string, headers map[string]string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/", strings.NewReader(body))
+		r.Host = "127.0.0.1:7777"
+		for key, value := range headers {
+			r.Header.Set(key, value)
+		}
+		w := httptest.NewRecorder()
+		served := make(chan struct{})
+		go func() {
+			instance.ServeHTTP(w, r)
+			close(served)
+		}()
+		select {
+		case <-served:
+		case <-time.After(time.Second):
+			t.Fatal(method + " blocked")
+		}
+		return w
+	}
+	text := func() string {
+		block, _ := instance.Render(context.Background())
+		if block == nil {
+			return ""
+		}
+		return block.Full_text
+	}
+
+	tests := []struct {
+		name    string
+		method  string
+		body    string
+		headers map[string]string
+		code    int
+		text    string
+		refresh bool
+	}{
+		{"origin", "POST", "a", map[string]string{pushHeader: "1", "Origin": "http://example.com"}, http.StatusForbidden, "idle", false},
+		{"form", "POST", "a", map[string]string{"Content-Type": "text/plain"}, http.StatusForbidden, "idle", false},
+		{"text", "POST", "a", map[string]string{pushHeader: "1"}, http.StatusOK, "a", true},
+		{"json", "PUT", `{"full_text": "b"}`, map[string]string{"Content-Type": "application/json"}, http.StatusOK, "b", true},
+		{"invalid json", "POST", `{"full_text"`, map[string]string{"Content-Type": "application/json"}, http.StatusBadRequest, "b", false},
+		{"invalid ttl", "POST", `{"full_text": "c", "ttl": "soon"}`, map[string]string{"Content-Type": "application/json"}, http.StatusBadRequest, "b", false},
+		{"delete", "DELETE", "", nil, http.StatusOK, "idle", true},
+		{"patch", "PATCH", "", nil, http.StatusMethodNotAllowed, "idle", false},
+	}
+	for _, test := range tests {
+		w := serve(test.method, test.body, test.headers)
+		if w.Code != test.code {
+			t.Errorf("%s: status %d, want %d: %s", test.name, w.Code, test.code, w.Body.String())
+		}
+		if got := text(); got != test.text {
+			t.Errorf("%s: text %q, want %q", test.name, got, test.text)
+		}
+		select {
+		case <-refreshed:
+			if !test.refresh {
+				t.Errorf("%s: refreshed the block", test.name)
+			}
+		default:
+			if test.refresh {
+				t.Errorf("%s: didn't refresh the block", test.name)
+			}
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package modules
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// FieldType is the type a config value must have.
+type FieldType string
+
+const (
+	String FieldType = "string"
+	Int    FieldType = "int"
+	Number FieldType = "number"
+	Bool   FieldType = "bool"
+	// a string like "1m30s"
+	Duration FieldType = "duration"
+	// either an int or a string
+	IntOrString FieldType = "int or string"
+	List        FieldType = "list"
+	Object      FieldType = "object"
+	Any         FieldType = "any"
+)
+
+// Field describes a single config key.
+type Field struct {
+	Type     FieldType
+	Required bool
+	// the only values a string may take, if set
+	Allowed []string
+	Doc     string
+	// shown in the documentation, not applied by Check
+	Default interface{}
+}
+
+// Schema maps the config keys of a module to their description.
+type Schema map[string]Field
+
+// Check reports whether value is valid for the field.
+func (f Field) Check(value interface{}) error {
+	switch f.Type {
+	case String:
+		s, ok := value.(string)
+		if !ok {
+			return errors.New("must be a string")
+		}
+		return f.checkAllowed(s)
+	case Int:
+		if _, ok := ToInt(value); !ok {
+			return errors.New("must be an integer")
+		}
+	case Number:
+		if _, ok := ToFloat(value); !ok {
+			return errors.New("must be a number")
+		}
+	case Bool:
+		if _, ok := value.(bool); !ok {
+			return errors.New("must be true or false")
+		}
+	case Duration:
+		s, ok := value.(string)
+		if !ok {
+			return errors.New("must be a duration string like \"3s\"")
+		}
+		if _, err := time.ParseDuration(s); err != nil {
+			return err
+		}
+	case IntOrString:
+		if _, ok := value.(string); ok {
+			return nil
+		}
+		if _, ok := ToInt(value); !ok {
+			return errors.New("must be an integer or a string")
+		}
+	case List:
+		if v := reflect.ValueOf(value); !v.IsValid() || v.Kind() != reflect.Slice {
+			return errors.New("must be a list")
+		}
+	case Object:
+		if v := reflect.ValueOf(value); !v.IsValid() || v.Kind() != reflect.Map {
+			return errors.New("must be an object")
+		}
+	}
+	return nil
+}
+
+func (f Field) checkAllowed(s string) error {
+	if len(f.Allowed) == 0 {
+		return nil
+	}
+	for _, allowed := range f.Allowed {
+		if s == allowed {
+			return nil
+		}
+	}
+	return errors.New("must be one of " + strings.Join(f.Allowed, ", "))
+}
+
+// ToInt converts a number decoded from the config to an int. JSON numbers
+// are always decoded as float64, those are accepted if they are integral.
+func ToInt(value interface{}) (int, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		if f := v.Float(); f == math.Trunc(f) {
+			return int(f), true
+		}
+	}
+	return 0, false
+}
+
+// ToFloat converts a number decoded from the config to a float64.
+func ToFloat(value interface{}) (float64, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+// BlockSchema describes the block fields that can be set for every
+// instance.
+var BlockSchema = Schema{
+	"full_text":             {Type: String, Doc: "fixed text of the block"},
+	"short_text":            {Type: String, Doc: "text used when the bar is too narrow"},
+	"color":                 {Type: String, Doc: "text color, e.g. #FF0000"},
+	"background":            {Type: String, Doc: "background color"},
+	"border":                {Type: String, Doc: "border color"},
+	"border_top":            {Type: Int, Doc: "width of the top border in pixels"},
+	"border_right":          {Type: Int, Doc: "width of the right border in pixels"},
+	"border_bottom":         {Type: Int, Doc: "width of the bottom border in pixels"},
+	"border_left":           {Type: Int, Doc: "width of the left border in pixels"},
+	"min_width":             {Type: IntOrString, Doc: "minimum width in pixels or of the given text"},
+	"align":                 {Type: String, Allowed: []string{"left", "center", "right"}, Doc: "alignment of the text within min_width"},
+	"urgent":                {Type: Bool, Doc: "mark the block as urgent"},
+	"instance":              {Type: String, Doc: "instance passed back in click events"},
+	"separator":             {Type: Bool, Doc: "draw a separator after the block"},
+	"separator_block_width": {Type: Int, Doc: "gap after the block in pixels"},
+	"markup":                {Type: String, Allowed: []string{"pango", "none"}, Doc: "how full_text is interpreted"},
+}
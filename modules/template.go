@@ -0,0 +1,62 @@
+package modules
+
+import (
+	"text/template"
+	"text/template/parse"
+)
+
+// NewTemplate parses the format of a block. Besides the functions of the
+// module in funcs the format can use the markup helpers escape, span, bold
+// and italic and the shared functions of templateFuncs.
+//
+// If the block is rendered with pango markup the output of every action is
+// escaped, so that an "&" in an MPD title doesn't break the block. Markup
+// written in the format itself and the results of the helpers are kept.
+func NewTemplate(name string, format string, markup string, funcs template.FuncMap) (*template.Template, error) {
+	t, err := template.New(name).Funcs(templateFuncs).Funcs(markupFuncs).Funcs(funcs).Parse(format)
+	if err != nil {
+		return nil, err
+	}
+	if markup == "pango" {
+		for _, defined := range t.Templates() {
+			if defined.Tree != nil {
+				escapeActions(defined.Tree, defined.Tree.Root)
+			}
+		}
+	}
+	return t, nil
+}
+
+// escapeActions appends the escaping function to the pipeline of every
+// action that writes output, much like html/template does.
+func escapeActions(tree *parse.Tree, node parse.Node) {
+	switch node := node.(type) {
+	case *parse.ListNode:
+		if node == nil {
+			return
+		}
+		for _, child := range node.Nodes {
+			escapeActions(tree, child)
+		}
+	case *parse.ActionNode:
+		// declarations and assignments print nothing
+		if len(node.Pipe.Decl) > 0 {
+			return
+		}
+		escape := parse.NewIdentifier(autoEscape).SetTree(tree).SetPos(node.Pos)
+		node.Pipe.Cmds = append(node.Pipe.Cmds, &parse.CommandNode{
+			NodeType: parse.NodeCommand,
+			Pos:      node.Pos,
+			Args:     []parse.Node{escape},
+		})
+	case *parse.IfNode:
+		escapeActions(tree, node.List)
+		escapeActions(tree, node.ElseList)
+	case *parse.RangeNode:
+		escapeActions(tree, node.List)
+		escapeActions(tree, node.ElseList)
+	case *parse.WithNode:
+		escapeActions(tree, node.List)
+		escapeActions(tree, node.ElseList)
+	}
+}
@@ -0,0 +1,117 @@
+package modules
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewTemplateEscaping(t *testing.T) {
+	data := map[string]interface{}{
+		"Title":  "Rock & Roll",
+		"Tags":   []string{"<a>", "b&c"},
+		"Nested": map[string]string{"Name": "x<y"},
+		"Empty":  "",
+		"None":   []string{},
+	}
+
+	tests := []struct {
+		name   string
+		format string
+		pango  string
+		none   string
+	}{
+		{
+			name:   "field",
+			format: "{{ .Title }}",
+			pango:  "Rock &amp; Roll",
+			none:   "Rock & Roll",
+		},
+		{
+			name:   "markup in the format is kept",
+			format: "<b>{{ .Title }}</b>",
+			pango:  "<b>Rock &amp; Roll</b>",
+			none:   "<b>Rock & Roll</b>",
+		},
+		{
+			name:   "pipeline",
+			format: `{{ .Title | printf "%s!" }}`,
+			pango:  "Rock &amp; Roll!",
+			none:   "Rock & Roll!",
+		},
+		{
+			name:   "helpers are not escaped twice",
+			format: `{{ bold .Title }} {{ .Title | italic }} {{ span "color" "red" .Title }} {{ escape .Title }}`,
+			pango:  `<b>Rock &amp; Roll</b> <i>Rock &amp; Roll</i> <span color="red">Rock &amp; Roll</span> Rock &amp; Roll`,
+			none:   `<b>Rock &amp; Roll</b> <i>Rock &amp; Roll</i> <span color="red">Rock &amp; Roll</span> Rock &amp; Roll`,
+		},
+		{
+			name:   "if",
+			format: "{{ if .Title }}{{ .Title }}{{ else }}-{{ end }}",
+			pango:  "Rock &amp; Roll",
+			none:   "Rock & Roll",
+		},
+		{
+			name:   "else",
+			format: "{{ if .Empty }}{{ .Title }}{{ else }}{{ .Nested.Name }}{{ end }}",
+			pango:  "x&lt;y",
+			none:   "x<y",
+		},
+		{
+			name:   "with",
+			format: "{{ with .Nested }}{{ .Name }}{{ end }}",
+			pango:  "x&lt;y",
+			none:   "x<y",
+		},
+		{
+			name:   "with else",
+			format: "{{ with .Empty }}{{ . }}{{ else }}{{ .Title }}{{ end }}",
+			pango:  "Rock &amp; Roll",
+			none:   "Rock & Roll",
+		},
+		{
+			name:   "range",
+			format: "{{ range $i, $v := .Tags }}{{ if $i }}, {{ end }}{{ $v }}{{ end }}",
+			pango:  "&lt;a&gt;, b&amp;c",
+			none:   "<a>, b&c",
+		},
+		{
+			name:   "range else",
+			format: "{{ range .None }}{{ . }}{{ else }}{{ .Title }}{{ end }}",
+			pango:  "Rock &amp; Roll",
+			none:   "Rock & Roll",
+		},
+		{
+			name:   "declarations print nothing",
+			format: "{{ $t := .Title }}{{ $t = .Nested.Name }}{{ $t }}",
+			pango:  "x&lt;y",
+			none:   "x<y",
+		},
+		{
+			name:   "define",
+			format: `{{ define "name" }}{{ .Name }}{{ end }}{{ template "name" .Nested }}`,
+			pango:  "x&lt;y",
+			none:   "x<y",
+		},
+		{
+			name:   "block",
+			format: `{{ block "title" . }}[{{ .Title }}]{{ end }}`,
+			pango:  "[Rock &amp; Roll]",
+			none:   "[Rock & Roll]",
+		},
+	}
+	for _, test := range tests {
+		for markup, want := range map[string]string{"pango": test.pango, "none": test.none} {
+			tmpl, err := NewTemplate("test", test.format, markup, nil)
+			if err != nil {
+				t.Errorf("%s (%s): %s", test.name, markup, err)
+				continue
+			}
+			var out strings.Builder
+			if err := tmpl.Execute(&out, data); err != nil {
+				t.Errorf("%s (%s): %s", test.name, markup, err)
+			} else if out.String() != want {
+				t.Errorf("%s (%s) = %q, want %q", test.name, markup, out.String(), want)
+			}
+		}
+	}
+}
@@ -1,7 +1,7 @@
 package time
 
 import (
-	"encoding/json"
+	"context"
 	"github.com/andir/go3status/modules"
 	"github.com/op/go-logging"
 	"time"
@@ -9,14 +9,22 @@ import (
 
 var log = logging.MustGetLogger("go3status.time")
 
+type Config struct {
+	Format     string `config:"format" default:"Mon, 02.01.2006 15:04:05 MST" doc:"Go time layout"`
+	Format_alt string `config:"format_alt" doc:"layout shown instead of format after clicking the block"`
+}
+
 type TimeInstance struct {
 	name   string
-	config map[string]interface{}
+	config *Config
 	format string
+	// format_alt is shown instead of format after a click on the block
+	format_alt string
+	alt        bool
 }
 
-func (t TimeInstance) RefreshInterval() int {
-	return 1
+func (t TimeInstance) RefreshInterval() time.Duration {
+	return 1 * time.Second
 }
 
 func (t TimeInstance) Name() (n string) {
@@ -24,8 +32,8 @@ func (t TimeInstance) Name() (n string) {
 	return
 }
 
-func (t TimeInstance) Config() (m map[string]interface{}) {
-	m = t.config
+func (t TimeInstance) Config() (c *Config) {
+	c = t.config
 	return
 }
 
@@ -34,48 +42,43 @@ func (t TimeInstance) String() (s string) {
 	return
 }
 
-func (t TimeInstance) Render() (item modules.Item) {
-	item = RenderInstance(t)
-	return
-}
-
-type TimeItem struct {
-	Name string `json:"name"`
-	Text string `json:"full_text"`
+func (t *TimeInstance) Click(event modules.ClickEvent) {
+	if t.format_alt != "" {
+		t.alt = !t.alt
+	}
 }
 
-func (e TimeItem) Marshal() (bytes []byte) {
-	var err error
-	if bytes, err = json.Marshal(e); err != nil {
-		log.Error(err.Error())
-	}
+func (t *TimeInstance) Render(ctx context.Context) (block *modules.Block, err error) {
+	block, err = RenderInstance(ctx, t)
 	return
 }
 
-func RenderInstance(i modules.ModuleInstance) (t modules.Item) {
+func RenderInstance(ctx context.Context, i modules.ModuleInstance) (b *modules.Block, err error) {
+
+	instance := i.(*TimeInstance)
 
-	instance := i.(TimeInstance)
+	format := instance.format
+	if instance.alt {
+		format = instance.format_alt
+	}
 
 	now := time.Now()
-	formatted := now.Format(instance.format)
-	t = modules.Item(TimeItem{Name: instance.name, Text: formatted})
+	formatted := now.Format(format)
+	b = &modules.Block{Name: instance.name, Full_text: formatted}
 
 	return
 }
 
-func CreateInstance(name string, config map[string]interface{}) (m modules.ModuleInstance) {
+func CreateInstance(name string, config interface{}) (m modules.ModuleInstance, err error) {
+	c := config.(*Config)
 	f := TimeInstance{
-		name:   name,
-		config: config,
-	}
-
-	if i, ok := config["format"]; ok {
-		f.format = i.(string)
-	} else {
-		f.format = "Mon, 02.01.2006 15:04:05 MST"
+		name:       name,
+		config:     c,
+		format:     c.Format,
+		format_alt: c.Format_alt,
 	}
 
-	m = modules.ModuleInstance(f)
+	m = modules.ModuleInstance(&f)
 
 	return
 }
@@ -83,4 +86,5 @@ func CreateInstance(name string, config map[string]interface{}) (m modules.Modul
 var Module = modules.Module{
 	Name:           "time",
 	CreateInstance: CreateInstance,
+	NewConfig:      func() interface{} { return &Config{} },
 }
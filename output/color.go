@@ -0,0 +1,71 @@
+package output
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RGB is a color without transparency.
+type RGB struct {
+	R, G, B uint8
+}
+
+func (c RGB) Hex() string {
+	return fmt.Sprintf("#%02X%02X%02X", c.R, c.G, c.B)
+}
+
+// the color names of pango most likely to be used in formats
+var colorNames = map[string]RGB{
+	"black":   {0x00, 0x00, 0x00},
+	"white":   {0xFF, 0xFF, 0xFF},
+	"red":     {0xFF, 0x00, 0x00},
+	"green":   {0x00, 0xFF, 0x00},
+	"blue":    {0x00, 0x00, 0xFF},
+	"yellow":  {0xFF, 0xFF, 0x00},
+	"cyan":    {0x00, 0xFF, 0xFF},
+	"magenta": {0xFF, 0x00, 0xFF},
+	"orange":  {0xFF, 0xA5, 0x00},
+	"purple":  {0xA0, 0x20, 0xF0},
+	"pink":    {0xFF, 0xC0, 0xCB},
+	"brown":   {0xA5, 0x2A, 0x2A},
+	"grey":    {0xBE, 0xBE, 0xBE},
+	"gray":    {0xBE, 0xBE, 0xBE},
+}
+
+// ParseColor understands #RGB, #RRGGBB, #RRGGBBAA and the common color
+// names.
+func ParseColor(s string) (c RGB, ok bool) {
+	s = strings.TrimSpace(s)
+	if c, ok = colorNames[strings.ToLower(s)]; ok {
+		return
+	}
+	if !strings.HasPrefix(s, "#") {
+		return
+	}
+
+	hex := s[1:]
+	switch len(hex) {
+	case 3:
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	case 6:
+	case 8:
+		// the alpha channel is dropped
+		hex = hex[:6]
+	default:
+		return
+	}
+	n, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return
+	}
+	return RGB{uint8(n >> 16), uint8(n >> 8), uint8(n)}, true
+}
+
+// hexColor normalizes a color to #RRGGBB, unknown colors become "".
+func hexColor(s string) string {
+	if c, ok := ParseColor(s); ok {
+		return c.Hex()
+	}
+	return ""
+}
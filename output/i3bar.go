@@ -0,0 +1,66 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/andir/go3status/modules"
+)
+
+// i3bar speaks the JSON protocol of i3bar, which swaybar understands as
+// well.
+type i3bar struct{}
+
+// i3barHeader is the first message of the i3bar protocol.
+type i3barHeader struct {
+	Version      int  `json:"version"`
+	Stop_signal  int  `json:"stop_signal"`
+	Cont_signal  int  `json:"cont_signal"`
+	Click_events bool `json:"click_events"`
+}
+
+/*
+	{"stop_signal": 20, "click_events": true, "version": 1, "cont_signal": 18}
+	[
+	[],
+	[{"color": "#AAAAAA", "separator_block_width": 0, "name": "traffic-wl0_rx", "markup": "pango", "full_text": "_",
+	 "separator": false}, {"color": "#AAAAAA", "name": "traffic-wl0_tx", "markup": "pango", "full_text": "_"},
+	 {"color": "#FFFFFF", "name": "wireless_default", "full_text": "darmstadt.freifunk.net"},
+	 {"color": "#AAAAAA", "name": "datetime_default", "full_text": "01:33:59"}
+	],
+*/
+
+func (i3bar) Begin(w io.Writer, header Header) error {
+	preamble, err := json.Marshal(i3barHeader{
+		Version:      1,
+		Stop_signal:  header.Stop_signal,
+		Cont_signal:  header.Cont_signal,
+		Click_events: true,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n[\n[],\n", preamble)
+	return err
+}
+
+func (i3bar) Write(w io.Writer, blocks []*modules.Block) error {
+	s := []string{}
+	for _, block := range blocks {
+		if block != nil {
+			s = append(s, string(block.Marshal()))
+		}
+	}
+	_, err := fmt.Fprintln(w, "[\n"+strings.Join(s, ",\n")+"],\n")
+	return err
+}
+
+func (i3bar) ClickEvents() bool {
+	return true
+}
+
+func (i3bar) Pauses() bool {
+	return true
+}
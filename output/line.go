@@ -0,0 +1,225 @@
+package output
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/andir/go3status/modules"
+)
+
+// format describes the inline markup of a bar that reads one line of text
+// per update.
+type format struct {
+	// style wraps escaped text in the markup for its style, the colors are
+	// either #RRGGBB or empty
+	style func(text string, style Style) string
+	// escape protects text from being read as markup
+	escape func(text string) string
+	// separator goes between blocks unless a block disables its separator
+	separator string
+}
+
+// line writes one line per update using the markup of its format.
+type line struct {
+	format format
+}
+
+func (l *line) Begin(w io.Writer, header Header) error {
+	return nil
+}
+
+func (l *line) Write(w io.Writer, blocks []*modules.Block) error {
+	_, err := io.WriteString(w, l.format.line(blocks)+"\n")
+	return err
+}
+
+func (l *line) ClickEvents() bool {
+	return false
+}
+
+func (l *line) Pauses() bool {
+	return false
+}
+
+// line joins the blocks into a single line of markup.
+func (f format) line(blocks []*modules.Block) string {
+	var text strings.Builder
+	separator := ""
+	for _, block := range blocks {
+		if block == nil {
+			continue
+		}
+		text.WriteString(separator)
+		text.WriteString(f.block(block))
+		separator = f.separator
+		if block.Separator != nil && !*block.Separator {
+			separator = " "
+		}
+	}
+	return text.String()
+}
+
+// block converts the text of a block to the markup of the format.
+func (f format) block(block *modules.Block) string {
+	var text strings.Builder
+	for _, segment := range Segments(block) {
+		style := segment.Style
+		style.Foreground = hexColor(style.Foreground)
+		style.Background = hexColor(style.Background)
+		text.WriteString(f.style(f.escape(segment.Text), style))
+	}
+	return text.String()
+}
+
+var plain = format{
+	style:     func(text string, style Style) string { return text },
+	escape:    func(text string) string { return text },
+	separator: " | ",
+}
+
+var lemonbar = format{
+	style: func(text string, style Style) string {
+		if style.Underline {
+			text = "%{+u}" + text + "%{-u}"
+		}
+		if style.Background != "" {
+			text = "%{B" + style.Background + "}" + text + "%{B-}"
+		}
+		if style.Foreground != "" {
+			text = "%{F" + style.Foreground + "}" + text + "%{F-}"
+		}
+		return text
+	},
+	escape:    func(text string) string { return strings.Replace(text, "%", "%%", -1) },
+	separator: " | ",
+}
+
+var dzen2 = format{
+	style: func(text string, style Style) string {
+		if style.Background != "" {
+			text = "^bg(" + style.Background + ")" + text + "^bg()"
+		}
+		if style.Foreground != "" {
+			text = "^fg(" + style.Foreground + ")" + text + "^fg()"
+		}
+		return text
+	},
+	escape:    func(text string) string { return strings.Replace(text, "^", "^^", -1) },
+	separator: " | ",
+}
+
+var xmobar = format{
+	// xmobar can't set a background without a foreground
+	style: func(text string, style Style) string {
+		switch {
+		case style.Foreground != "" && style.Background != "":
+			return "<fc=" + style.Foreground + "," + style.Background + ">" + text + "</fc>"
+		case style.Foreground != "":
+			return "<fc=" + style.Foreground + ">" + text + "</fc>"
+		}
+		return text
+	},
+	escape:    func(text string) string { return strings.Replace(text, "<", "<raw=1:</>", -1) },
+	separator: " | ",
+}
+
+var tmux = format{
+	style: func(text string, style Style) string {
+		attributes := []string{}
+		if style.Foreground != "" {
+			attributes = append(attributes, "fg="+style.Foreground)
+		}
+		if style.Background != "" {
+			attributes = append(attributes, "bg="+style.Background)
+		}
+		if style.Bold {
+			attributes = append(attributes, "bold")
+		}
+		if style.Italic {
+			attributes = append(attributes, "italics")
+		}
+		if style.Underline {
+			attributes = append(attributes, "underscore")
+		}
+		if style.Strikethrough {
+			attributes = append(attributes, "strikethrough")
+		}
+		if len(attributes) == 0 {
+			return text
+		}
+		return "#[" + strings.Join(attributes, ",") + "]" + text + "#[default]"
+	},
+	escape:    func(text string) string { return strings.Replace(text, "#", "##", -1) },
+	separator: " | ",
+}
+
+// ansi uses the 24 bit color escape sequences most terminals understand.
+var ansi = format{
+	style: func(text string, style Style) string {
+		codes := []string{}
+		if c, ok := ParseColor(style.Foreground); ok {
+			codes = append(codes, fmt.Sprintf("38;2;%d;%d;%d", c.R, c.G, c.B))
+		}
+		if c, ok := ParseColor(style.Background); ok {
+			codes = append(codes, fmt.Sprintf("48;2;%d;%d;%d", c.R, c.G, c.B))
+		}
+		if style.Bold {
+			codes = append(codes, "1")
+		}
+		if style.Italic {
+			codes = append(codes, "3")
+		}
+		if style.Underline {
+			codes = append(codes, "4")
+		}
+		if style.Strikethrough {
+			codes = append(codes, "9")
+		}
+		if len(codes) == 0 {
+			return text
+		}
+		return "\x1b[" + strings.Join(codes, ";") + "m" + text + "\x1b[0m"
+	},
+	// escape sequences in the text would mess up the terminal
+	escape:    func(text string) string { return terminalControls.ReplaceAllString(text, "") },
+	separator: " | ",
+}
+
+// terminalControls matches whole CSI and OSC sequences, other escape
+// sequences and the remaining control characters except tabs. Dropping
+// just the escape character would leave e.g. "[2J" behind.
+var terminalControls = regexp.MustCompile(`(?:\x1b\[|\x{9b})[0-?]*[ -/]*[@-~]?|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)?|\x1b[ -~]?|[\x00-\x08\x0a-\x1f\x7f\x{80}-\x{9f}]`)
+
+// pango is the markup of Waybar.
+var pango = format{
+	style: func(text string, style Style) string {
+		attributes := ""
+		if style.Foreground != "" {
+			attributes += ` foreground="` + style.Foreground + `"`
+		}
+		if style.Background != "" {
+			attributes += ` background="` + style.Background + `"`
+		}
+		if style.Bold {
+			attributes += ` weight="bold"`
+		}
+		if style.Italic {
+			attributes += ` style="italic"`
+		}
+		if style.Underline {
+			attributes += ` underline="single"`
+		}
+		if style.Strikethrough {
+			attributes += ` strikethrough="true"`
+		}
+		if attributes == "" {
+			return text
+		}
+		return "<span" + attributes + ">" + text + "</span>"
+	},
+	escape:    html.EscapeString,
+	separator: " | ",
+}
@@ -0,0 +1,58 @@
+package output
+
+import (
+	"testing"
+
+	"github.com/andir/go3status/modules"
+)
+
+func TestFormatBlock(t *testing.T) {
+	colored := &modules.Block{Full_text: `<span color="red">a</span> b`, Color: "#00FF00", Markup: "pango"}
+	tests := []struct {
+		name   string
+		format format
+		block  *modules.Block
+		want   string
+	}{
+		{"plain", plain, colored, "a b"},
+		{"plain keeps markup characters", plain, &modules.Block{Full_text: "100% <#^>"}, "100% <#^>"},
+		{"lemonbar", lemonbar, colored, "%{F#FF0000}a%{F-}%{F#00FF00} b%{F-}"},
+		{"lemonbar escapes %", lemonbar, &modules.Block{Full_text: "100%"}, "100%%"},
+		{"lemonbar underline", lemonbar, &modules.Block{Full_text: "<u>x</u>", Markup: "pango"}, "%{+u}x%{-u}"},
+		{"dzen2", dzen2, colored, "^fg(#FF0000)a^fg()^fg(#00FF00) b^fg()"},
+		{"dzen2 escapes ^", dzen2, &modules.Block{Full_text: "a^b"}, "a^^b"},
+		{"xmobar", xmobar, &modules.Block{Full_text: "x", Color: "#FF0000", Background: "#000000"}, "<fc=#FF0000,#000000>x</fc>"},
+		{"xmobar without foreground", xmobar, &modules.Block{Full_text: "x", Background: "#000000"}, "x"},
+		{"xmobar escapes <", xmobar, &modules.Block{Full_text: "a<b"}, "a<raw=1:</>b"},
+		{"tmux", tmux, &modules.Block{Full_text: "<b>x</b>", Color: "#FF0000", Markup: "pango"}, "#[fg=#FF0000,bold]x#[default]"},
+		{"tmux escapes #", tmux, &modules.Block{Full_text: "#1"}, "##1"},
+		{"ansi", ansi, &modules.Block{Full_text: "<i>x</i>", Color: "#FF0000", Markup: "pango"}, "\x1b[38;2;255;0;0;3mx\x1b[0m"},
+		{"ansi drops escape sequences", ansi, &modules.Block{Full_text: "a\x1b[2Jb"}, "ab"},
+		{"ansi drops private sequences", ansi, &modules.Block{Full_text: "\x1b[?25la\x1b[1;31mb"}, "ab"},
+		{"ansi drops titles", ansi, &modules.Block{Full_text: "\x1b]0;title\x07a\x1b]2;x\x1b\\b"}, "ab"},
+		{"ansi drops short sequences", ansi, &modules.Block{Full_text: "\x1b7a\x1bcb\x1b"}, "ab"},
+		{"ansi drops control characters", ansi, &modules.Block{Full_text: "a\rb\x08c\u009b2Jd\te"}, "abcd\te"},
+		{"pango", pango, &modules.Block{Full_text: "<b>x</b>", Color: "red", Markup: "pango"}, `<span foreground="#FF0000" weight="bold">x</span>`},
+		{"pango escapes text", pango, &modules.Block{Full_text: "a & <b>"}, "a &amp; &lt;b&gt;"},
+		{"pango keeps escaped markup escaped", pango, &modules.Block{Full_text: "a &amp; b", Markup: "pango"}, "a &amp; b"},
+		{"unknown colors are dropped", tmux, &modules.Block{Full_text: "x", Color: "chartreuse-ish"}, "x"},
+	}
+	for _, test := range tests {
+		if got := test.format.block(test.block); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestFormatLine(t *testing.T) {
+	off := false
+	blocks := []*modules.Block{
+		{Full_text: "a"},
+		nil,
+		{Full_text: "b", Separator: &off},
+		{Full_text: "c"},
+	}
+	if got, want := plain.line(blocks), "a | b c"; got != want {
+		t.Errorf("line = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,67 @@
+// Package output writes the blocks in the formats of different status bars.
+package output
+
+import (
+	"errors"
+	"io"
+	"sort"
+
+	"github.com/andir/go3status/modules"
+)
+
+// Header holds what a bar has to know before the first update.
+type Header struct {
+	// the signals the bar sends to pause and resume go3status
+	Stop_signal int
+	Cont_signal int
+}
+
+// Backend writes the blocks for one kind of status bar.
+type Backend interface {
+	// Begin writes whatever precedes the first update.
+	Begin(w io.Writer, header Header) error
+	// Write writes one update of the bar, hidden blocks are nil.
+	Write(w io.Writer, blocks []*modules.Block) error
+	// ClickEvents reports whether the bar sends click events on stdin.
+	ClickEvents() bool
+	// Pauses reports whether the bar sends the stop and cont signals of
+	// the header. Otherwise they keep their usual meaning, e.g. Ctrl-Z in
+	// a terminal.
+	Pauses() bool
+}
+
+var backends = map[string]func() Backend{
+	"i3bar":    func() Backend { return i3bar{} },
+	"swaybar":  func() Backend { return i3bar{} },
+	"plain":    func() Backend { return &line{format: plain} },
+	"lemonbar": func() Backend { return &line{format: lemonbar} },
+	"dzen2":    func() Backend { return &line{format: dzen2} },
+	"xmobar":   func() Backend { return &line{format: xmobar} },
+	"waybar":   func() Backend { return waybar{} },
+	"tmux":     func() Backend { return &line{format: tmux} },
+	"ansi":     func() Backend { return &line{format: ansi} },
+}
+
+// New returns the backend of the given name.
+func New(name string) (Backend, error) {
+	backend, ok := backends[name]
+	if !ok {
+		return nil, errors.New("unknown output: " + name)
+	}
+	return backend(), nil
+}
+
+// Names returns the names of all backends.
+func Names() (names []string) {
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// Has reports whether there is a backend of the given name.
+func Has(name string) bool {
+	_, ok := backends[name]
+	return ok
+}
@@ -0,0 +1,135 @@
+package output
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/andir/go3status/modules"
+)
+
+// Style is the formatting of a piece of text.
+type Style struct {
+	Foreground    string
+	Background    string
+	Bold          bool
+	Italic        bool
+	Underline     bool
+	Strikethrough bool
+}
+
+// Segment is a piece of text formatted the same way throughout.
+type Segment struct {
+	Text  string
+	Style Style
+}
+
+var attributePattern = regexp.MustCompile(`([a-z_]+)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+
+// apply changes the style according to an opening pango tag.
+func (s Style) apply(tag string) Style {
+	fields := strings.Fields(tag)
+	if len(fields) == 0 {
+		return s
+	}
+	switch fields[0] {
+	case "b":
+		s.Bold = true
+	case "i":
+		s.Italic = true
+	case "u":
+		s.Underline = true
+	case "s":
+		s.Strikethrough = true
+	case "span":
+		for _, m := range attributePattern.FindAllStringSubmatch(tag, -1) {
+			value := m[2] + m[3]
+			switch m[1] {
+			case "foreground", "fgcolor", "color":
+				s.Foreground = value
+			case "background", "bgcolor":
+				s.Background = value
+			case "weight", "font_weight":
+				n, err := strconv.Atoi(value)
+				s.Bold = value == "bold" || value == "heavy" || value == "ultrabold" || (err == nil && n >= 600)
+			case "style", "font_style":
+				s.Italic = value == "italic" || value == "oblique"
+			case "underline":
+				s.Underline = value != "none"
+			case "strikethrough":
+				s.Strikethrough = value == "true"
+			}
+		}
+	}
+	return s
+}
+
+// ParseMarkup splits pango markup into segments. Tags that don't change the
+// style of the text, like <big>, are dropped.
+func ParseMarkup(markup string, base Style) (segments []Segment) {
+	stack := []Style{base}
+	add := func(text string) {
+		if text == "" {
+			return
+		}
+		segments = append(segments, Segment{html.UnescapeString(text), stack[len(stack)-1]})
+	}
+
+	for markup != "" {
+		start := strings.IndexByte(markup, '<')
+		if start < 0 {
+			add(markup)
+			break
+		}
+		end := strings.IndexByte(markup[start:], '>')
+		if end < 0 {
+			// not markup after all
+			add(markup)
+			break
+		}
+		tag := strings.TrimSpace(markup[start+1 : start+end])
+		if tag == "" {
+			// pango rejects <>, it is shown as it is
+			add(markup[:start+end+1])
+			markup = markup[start+end+1:]
+			continue
+		}
+		add(markup[:start])
+		markup = markup[start+end+1:]
+
+		switch {
+		case strings.HasPrefix(tag, "/"):
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+		case strings.HasSuffix(tag, "/"):
+		default:
+			stack = append(stack, stack[len(stack)-1].apply(tag))
+		}
+	}
+	return
+}
+
+// Segments returns the text of a block along with its formatting. The
+// color and background of the block apply where the markup sets none.
+func Segments(block *modules.Block) []Segment {
+	base := Style{Foreground: block.Color, Background: block.Background}
+	if block.Urgent {
+		// the colors i3bar uses for urgent workspaces
+		base = Style{Foreground: "#FFFFFF", Background: "#900000"}
+	}
+	if block.Markup == "pango" {
+		return ParseMarkup(block.Full_text, base)
+	}
+	return []Segment{{block.Full_text, base}}
+}
+
+// PlainText returns the text of a block without any markup.
+func PlainText(block *modules.Block) string {
+	var text strings.Builder
+	for _, segment := range Segments(block) {
+		text.WriteString(segment.Text)
+	}
+	return text.String()
+}
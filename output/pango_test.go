@@ -0,0 +1,87 @@
+package output
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/andir/go3status/modules"
+)
+
+func TestParseMarkup(t *testing.T) {
+	base := Style{Foreground: "#AAAAAA"}
+	bold := base
+	bold.Bold = true
+	red := base
+	red.Foreground = "#FF0000"
+	redBold := red
+	redBold.Bold = true
+
+	tests := []struct {
+		markup string
+		want   []Segment
+	}{
+		{"", nil},
+		{"plain", []Segment{{"plain", base}}},
+		{"a &amp; b &lt;c&gt;", []Segment{{"a & b <c>", base}}},
+		{"a <b>b</b> c", []Segment{{"a ", base}, {"b", bold}, {" c", base}}},
+		{`<span color="#FF0000">red</span>`, []Segment{{"red", red}}},
+		{`<span foreground='#FF0000' weight="bold">x</span>`, []Segment{{"x", redBold}}},
+		{`<span weight="700">x</span>`, []Segment{{"x", bold}}},
+		{`<span weight="normal">x</span>`, []Segment{{"x", base}}},
+		{`<span color="#FF0000">a<b>b</b></span>c`, []Segment{{"a", red}, {"b", redBold}, {"c", base}}},
+		{`<span background="#000000" underline="single" strikethrough="true" style="italic">x</span>`, []Segment{
+			{"x", Style{Foreground: "#AAAAAA", Background: "#000000", Underline: true, Strikethrough: true, Italic: true}},
+		}},
+		{"<big>big</big>", []Segment{{"big", base}}},
+		{"a<br/>b", []Segment{{"a", base}, {"b", base}}},
+		{"</b>unbalanced", []Segment{{"unbalanced", base}}},
+		{"1 < 2", []Segment{{"1 < 2", base}}},
+		// empty tags are text, not markup
+		{"a<>b", []Segment{{"a<>", base}, {"b", base}}},
+		{"< >x", []Segment{{"< >", base}, {"x", base}}},
+		{"<b>a<\t></b>c", []Segment{{"a<\t>", bold}, {"c", base}}},
+	}
+	for _, test := range tests {
+		if got := ParseMarkup(test.markup, base); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseMarkup(%q) = %+v, want %+v", test.markup, got, test.want)
+		}
+	}
+}
+
+func TestStyleApplyEmptyTag(t *testing.T) {
+	base := Style{Foreground: "#AAAAAA"}
+	for _, tag := range []string{"", " ", "\t"} {
+		if got := base.apply(tag); got != base {
+			t.Errorf("apply(%q) = %+v, want %+v", tag, got, base)
+		}
+	}
+}
+
+func TestSegments(t *testing.T) {
+	tests := []struct {
+		name  string
+		block modules.Block
+		want  []Segment
+	}{
+		{
+			name:  "without markup",
+			block: modules.Block{Full_text: "<b>x</b>", Color: "#FF0000"},
+			want:  []Segment{{"<b>x</b>", Style{Foreground: "#FF0000"}}},
+		},
+		{
+			name:  "pango",
+			block: modules.Block{Full_text: "<b>x</b>", Color: "#FF0000", Markup: "pango"},
+			want:  []Segment{{"x", Style{Foreground: "#FF0000", Bold: true}}},
+		},
+		{
+			name:  "urgent",
+			block: modules.Block{Full_text: "x", Color: "#FF0000", Background: "#000000", Urgent: true},
+			want:  []Segment{{"x", Style{Foreground: "#FFFFFF", Background: "#900000"}}},
+		},
+	}
+	for _, test := range tests {
+		if got := Segments(&test.block); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: Segments = %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package output
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/andir/go3status/modules"
+)
+
+// waybar feeds a custom module of Waybar set to "return-type": "json". All
+// blocks end up in the single text of the module.
+type waybar struct{}
+
+type waybarUpdate struct {
+	Text    string `json:"text"`
+	Tooltip string `json:"tooltip,omitempty"`
+	Class   string `json:"class,omitempty"`
+}
+
+func (waybar) Begin(w io.Writer, header Header) error {
+	return nil
+}
+
+func (waybar) Write(w io.Writer, blocks []*modules.Block) error {
+	update := waybarUpdate{Text: pango.line(blocks)}
+	lines := []string{}
+	for _, block := range blocks {
+		if block == nil {
+			continue
+		}
+		lines = append(lines, PlainText(block))
+		if block.Urgent {
+			update.Class = "urgent"
+		}
+	}
+	update.Tooltip = strings.Join(lines, "\n")
+
+	// the markup stays readable, Waybar doesn't mind
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(update)
+}
+
+func (waybar) ClickEvents() bool {
+	return false
+}
+
+func (waybar) Pauses() bool {
+	return false
+}
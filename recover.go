@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	modules "github.com/andir/go3status/modules"
+)
+
+// failing instances are retried at most this far apart
+const maxBackoff = 5 * time.Minute
+
+// Failure counters per instance name, served on /debug/vars when
+// -debug-addr is given.
+var (
+	renderFailures      = expvar.NewMap("render_failures")
+	renderPanics        = expvar.NewMap("render_panics")
+	consecutiveFailures = expvar.NewMap("consecutive_failures")
+)
+
+// recoverPanic turns a panic of the named instance into an error. It has to
+// be deferred directly.
+func recoverPanic(name string, err *error) {
+	if r := recover(); r != nil {
+		renderPanics.Add(name, 1)
+		log.Error(fmt.Sprintf("%s panicked: %v\n%s", name, r, debug.Stack()))
+		*err = fmt.Errorf("panic: %v", r)
+	}
+}
+
+// safeRender calls Render of the instance without letting a panic take
+// down the whole bar.
+func safeRender(ctx context.Context, instance modules.ModuleInstance) (block *modules.Block, err error) {
+	defer recoverPanic(instance.Name(), &err)
+	return instance.Render(ctx)
+}
+
+func safeClick(handler modules.ClickHandler, name string, event modules.ClickEvent) (err error) {
+	defer recoverPanic(name, &err)
+	handler.Click(event)
+	return
+}
+
+func safeWatch(updater modules.Updater, name string, update modules.UpdateFunc, refresh modules.RefreshFunc, done <-chan struct{}) (err error) {
+	defer recoverPanic(name, &err)
+	updater.Watch(update, refresh, done)
+	return
+}
+
+// countFailure records the outcome of a render of the named instance.
+func countFailure(name string, failures int) {
+	if failures > 0 {
+		renderFailures.Add(name, 1)
+	}
+	consecutive := new(expvar.Int)
+	consecutive.Set(int64(failures))
+	consecutiveFailures.Set(name, consecutive)
+}
+
+// backoff doubles the interval for every consecutive failure up to
+// maxBackoff. Intervals that are longer already are left alone.
+func backoff(interval time.Duration, failures int) time.Duration {
+	if interval >= maxBackoff {
+		return interval
+	}
+	for i := 1; i < failures && interval < maxBackoff; i++ {
+		interval *= 2
+	}
+	if interval > maxBackoff {
+		interval = maxBackoff
+	}
+	return interval
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		interval time.Duration
+		failures int
+		want     time.Duration
+	}{
+		{time.Second, 0, time.Second},
+		{time.Second, 1, time.Second},
+		{time.Second, 2, 2 * time.Second},
+		{time.Second, 3, 4 * time.Second},
+		{time.Second, 9, 256 * time.Second},
+		{time.Second, 10, maxBackoff},
+		{time.Second, 1000, maxBackoff},
+		{time.Minute, 3, 4 * time.Minute},
+		{time.Minute, 4, maxBackoff},
+		{maxBackoff, 5, maxBackoff},
+		{time.Hour, 0, time.Hour},
+		{time.Hour, 5, time.Hour},
+	}
+	for _, test := range tests {
+		if got := backoff(test.interval, test.failures); got != test.want {
+			t.Errorf("backoff(%s, %d) = %s, want %s", test.interval, test.failures, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	modules "github.com/andir/go3status/modules"
+)
+
+// reloader re-reads the config file whenever it is notified, on SIGHUP and
+// optionally when the file changes.
+type reloader struct {
+	fileName string
+	mods     map[string]modules.Module
+	notified chan struct{}
+}
+
+func newReloader(fileName string, mods map[string]modules.Module) *reloader {
+	return &reloader{
+		fileName: fileName,
+		mods:     mods,
+		notified: make(chan struct{}, 1),
+	}
+}
+
+// Notify requests a reload, requests arriving before the reload happened
+// are merged.
+func (r *reloader) Notify() {
+	select {
+	case r.notified <- struct{}{}:
+	default:
+	}
+}
+
+func (r *reloader) Notified() <-chan struct{} {
+	return r.notified
+}
+
+// reload reads the config again. Unlike at startup a config with errors is
+// rejected as a whole, so the bar keeps running on the old one.
+func (r *reloader) reload() (config *Config, interval time.Duration, instances []configuredInstance, err error) {
+	if config, err = readConfig(r.fileName); err != nil {
+		return
+	}
+
+	for _, p := range validateConfig(config, r.mods) {
+		if !p.warning {
+			log.Error(p.String())
+			if err == nil {
+				err = errors.New(p.String())
+			}
+		}
+	}
+	if err != nil {
+		return
+	}
+
+	if interval, err = barInterval(config); err != nil {
+		return
+	}
+
+	// dropping a block that can't be created would make it vanish from
+	// the bar, the old config is kept instead
+	if instances, err = parseConfig(config, r.mods); err != nil {
+		return
+	}
+	if len(instances) == 0 {
+		err = errors.New("no instances configured")
+	}
+	return
+}
@@ -0,0 +1,730 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	modules "github.com/andir/go3status/modules"
+)
+
+// BlockStore holds the latest block of every worker in bar order. Workers
+// publish into it concurrently, the main loop reads a snapshot whenever it
+// is notified about a change.
+type BlockStore struct {
+	sync.Mutex
+	workers []*worker
+	slots   map[*worker]*slot
+	// shown in front of all blocks, e.g. when reloading the config failed
+	notice  *modules.Block
+	changed chan struct{}
+}
+
+// slot is what the store knows about a worker.
+type slot struct {
+	block *modules.Block
+	// whether the worker has published anything yet
+	set    bool
+	hidden bool
+	// replaces full_text until textUntil
+	text      string
+	textUntil time.Time
+}
+
+func NewBlockStore() *BlockStore {
+	return &BlockStore{
+		slots:   make(map[*worker]*slot),
+		changed: make(chan struct{}, 1),
+	}
+}
+
+func (s *BlockStore) notify() {
+	// a pending notification already covers this change
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+}
+
+// Set publishes the block of a worker. Blocks of workers that have been
+// removed are dropped.
+func (s *BlockStore) Set(w *worker, block *modules.Block) {
+	s.Lock()
+	slot, ok := s.slots[w]
+	if ok {
+		slot.block = block
+		slot.set = true
+	}
+	s.Unlock()
+
+	if ok {
+		s.notify()
+	}
+}
+
+// SetNotice shows block in front of all other blocks, nil removes it.
+func (s *BlockStore) SetNotice(block *modules.Block) {
+	s.Lock()
+	s.notice = block
+	s.Unlock()
+
+	s.notify()
+}
+
+// update changes the slot of a worker.
+func (s *BlockStore) update(w *worker, change func(slot *slot)) {
+	s.Lock()
+	if slot, ok := s.slots[w]; ok {
+		change(slot)
+	}
+	s.Unlock()
+
+	s.notify()
+}
+
+// setWorkers replaces the workers, keeping the slots of those that are
+// still present.
+func (s *BlockStore) setWorkers(workers []*worker) {
+	s.Lock()
+	slots := make(map[*worker]*slot, len(workers))
+	for _, w := range workers {
+		if slots[w] = s.slots[w]; slots[w] == nil {
+			slots[w] = new(slot)
+		}
+	}
+	s.workers = workers
+	s.slots = slots
+	s.Unlock()
+
+	s.notify()
+}
+
+// replaceWorker hands the slot of a worker over to its replacement.
+func (s *BlockStore) replaceWorker(old *worker, w *worker) {
+	s.Lock()
+	for i := range s.workers {
+		if s.workers[i] == old {
+			s.workers[i] = w
+		}
+	}
+	s.slots[w] = s.slots[old]
+	delete(s.slots, old)
+	s.Unlock()
+}
+
+// view returns the block of a slot as it is shown in the bar.
+func (slot *slot) view(now time.Time, name string) *modules.Block {
+	if slot.hidden {
+		return nil
+	}
+	if slot.text == "" || now.After(slot.textUntil) {
+		return slot.block
+	}
+	var block modules.Block
+	if slot.block != nil {
+		block = *slot.block
+	}
+	block.Name = name
+	block.Full_text = slot.text
+	block.Short_text = ""
+	return &block
+}
+
+// Blocks returns a copy of the current blocks.
+func (s *BlockStore) Blocks() (blocks []*modules.Block) {
+	s.Lock()
+	defer s.Unlock()
+	now := time.Now()
+	blocks = make([]*modules.Block, 0, len(s.workers)+1)
+	if s.notice != nil {
+		blocks = append(blocks, s.notice)
+	}
+	for _, w := range s.workers {
+		blocks = append(blocks, s.slots[w].view(now, w.instance.Name()))
+	}
+	return
+}
+
+// Complete reports whether every worker has published a block, even if it
+// was empty.
+func (s *BlockStore) Complete() bool {
+	s.Lock()
+	defer s.Unlock()
+	for _, slot := range s.slots {
+		if !slot.set {
+			return false
+		}
+	}
+	return true
+}
+
+// Changed is signaled after the store has been updated.
+func (s *BlockStore) Changed() <-chan struct{} {
+	return s.changed
+}
+
+// gate holds back all workers while the bar is hidden.
+type gate struct {
+	sync.Mutex
+	// closed while the workers may run
+	opened chan struct{}
+}
+
+func newGate() *gate {
+	g := &gate{opened: make(chan struct{})}
+	close(g.opened)
+	return g
+}
+
+func (g *gate) Open() {
+	g.Lock()
+	defer g.Unlock()
+	select {
+	case <-g.opened:
+	default:
+		close(g.opened)
+	}
+}
+
+func (g *gate) Close() {
+	g.Lock()
+	defer g.Unlock()
+	select {
+	case <-g.opened:
+		g.opened = make(chan struct{})
+	default:
+	}
+}
+
+// wait blocks until the gate is open. It returns false if done was closed
+// in the meantime.
+func (g *gate) wait(done <-chan struct{}) bool {
+	g.Lock()
+	opened := g.opened
+	g.Unlock()
+
+	select {
+	case <-opened:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+// worker refreshes a single instance on its own goroutine.
+type worker struct {
+	instance modules.ModuleInstance
+	// the merged config of the block, to tell whether a reload changed it
+	config map[string]interface{}
+	// block fields set in the config of the instance
+	overrides map[string]interface{}
+	errors    errorSettings
+	// nil if the block has none
+	thresholds *thresholds
+	timeout    time.Duration
+	// overrides RefreshInterval of the instance if set
+	refresh time.Duration
+	// number of consecutive failed renders
+	failures int
+	// the last block rendered without an error
+	last *modules.Block
+	// a render that didn't finish in time
+	pending chan rendered
+	clicks  chan modules.ClickEvent
+	updates chan rendered
+	// refresh on SIGRTMIN+signal, 0 for none
+	signal int
+	// the variant of the config currently used
+	variant string
+	// forces a render right away
+	forced chan struct{}
+	gate   *gate
+	// counts running Watch calls
+	watchers *sync.WaitGroup
+	done     chan struct{}
+}
+
+// rendered is the outcome of a render, either by the worker or pushed by an
+// Updater.
+type rendered struct {
+	block *modules.Block
+	err   error
+}
+
+// requestRefresh makes the worker render as soon as possible.
+func (w *worker) requestRefresh() {
+	select {
+	case w.forced <- struct{}{}:
+	default:
+	}
+}
+
+// interval returns the time between two renders, failing instances are
+// backed off exponentially.
+func (w *worker) interval() time.Duration {
+	interval := w.refresh
+	if interval <= 0 {
+		interval = w.instance.RefreshInterval()
+	}
+	return backoff(interval, w.failures)
+}
+
+// next returns the time until the next render. Renders are aligned to
+// multiples of the interval on the wall clock, so e.g. a clock refreshed
+// every second is rendered right after the second changed and never
+// visibly skips one.
+func (w *worker) next() time.Duration {
+	interval := w.interval()
+	now := time.Now()
+	return now.Truncate(interval).Add(interval).Sub(now)
+}
+
+// render runs Render of the instance with the configured timeout. A render
+// that times out is kept running and waited for by the next call instead of
+// starting another one in parallel.
+func (w *worker) render(store *BlockStore) {
+	if w.pending == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), w.timeout)
+		pending := make(chan rendered, 1)
+		go func() {
+			defer cancel()
+			block, err := safeRender(ctx, w.instance)
+			pending <- rendered{block, err}
+		}()
+		w.pending = pending
+	}
+
+	timeout := time.NewTimer(w.timeout)
+	defer timeout.Stop()
+
+	select {
+	case r := <-w.pending:
+		w.finish(store, r)
+	case <-timeout.C:
+		w.timedOut(store, context.DeadlineExceeded)
+	case <-w.done:
+	}
+}
+
+// finish publishes the outcome of the pending render.
+func (w *worker) finish(store *BlockStore, r rendered) {
+	w.pending = nil
+	if errors.Is(r.err, context.DeadlineExceeded) {
+		w.timedOut(store, r.err)
+	} else {
+		w.publish(store, r.block, r.err)
+	}
+}
+
+// settle waits for a render that timed out and is still running. It
+// returns false if the worker was stopped in the meantime.
+func (w *worker) settle(store *BlockStore) bool {
+	if w.pending == nil {
+		return true
+	}
+	select {
+	case r := <-w.pending:
+		w.finish(store, r)
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+// timedOut keeps showing the last good block, marked as stale.
+func (w *worker) timedOut(store *BlockStore, err error) {
+	if w.last == nil {
+		w.publish(store, nil, err)
+		return
+	}
+
+	name := w.instance.Name()
+	w.failures++
+	countFailure(name, w.failures)
+	log.Warning(fmt.Sprintf("%s timed out after %s (%d in a row)", name, w.timeout, w.failures))
+	store.Set(w, w.errors.staleBlock(w.last))
+}
+
+func (w *worker) publish(store *BlockStore, block *modules.Block, err error) {
+	name := w.instance.Name()
+	if err != nil {
+		w.failures++
+		countFailure(name, w.failures)
+		log.Error(fmt.Sprintf("%s failed to render (%d in a row): %s", name, w.failures, err.Error()))
+		store.Set(w, w.errors.errorBlock(name, err, w.failures))
+		return
+	}
+
+	if w.failures > 0 {
+		w.failures = 0
+		countFailure(name, 0)
+	}
+	if block != nil {
+		if err := block.Apply(w.overrides); err != nil {
+			log.Error("Failed to apply block settings of " + name + ": " + err.Error())
+		}
+		w.thresholds.apply(block)
+	}
+	w.last = block
+	store.Set(w, block)
+}
+
+// run renders the block until the worker is stopped, with watch set Watch
+// of an Updater runs alongside.
+func (w *worker) run(store *BlockStore, watch bool) {
+	if updater, ok := w.instance.(modules.Updater); ok && watch {
+		w.watchers.Add(1)
+		go func() {
+			defer w.watchers.Done()
+			err := safeWatch(updater, w.instance.Name(), func(block *modules.Block, err error) {
+				select {
+				case w.updates <- rendered{block, err}:
+				case <-w.done:
+				}
+			}, w.requestRefresh, w.done)
+			if err != nil {
+				// polling keeps the block alive
+				log.Error(w.instance.Name() + " stopped pushing updates: " + err.Error())
+			}
+		}()
+	}
+
+	if !w.gate.wait(w.done) {
+		return
+	}
+	w.render(store)
+
+	timer := time.NewTimer(w.next())
+	for {
+		select {
+		case <-w.done:
+			timer.Stop()
+			return
+		case <-timer.C:
+		case <-w.forced:
+			if !timer.Stop() {
+				<-timer.C
+			}
+		case u := <-w.updates:
+			// pushed blocks don't touch the polling schedule
+			w.publish(store, u.block, u.err)
+			// hold back the updater while the bar is hidden
+			if !w.gate.wait(w.done) {
+				timer.Stop()
+				return
+			}
+			continue
+		case event := <-w.clicks:
+			// clicks are handled on the worker goroutine and after a
+			// render that timed out finished, so instances never see
+			// Click and Render concurrently
+			if !w.settle(store) {
+				timer.Stop()
+				return
+			}
+			handler := w.instance.(modules.ClickHandler)
+			if err := safeClick(handler, w.instance.Name(), event); err != nil {
+				log.Error("Failed to handle click: " + err.Error())
+			}
+			if !timer.Stop() {
+				<-timer.C
+			}
+		}
+
+		// while the bar is hidden nothing is rendered
+		if !w.gate.wait(w.done) {
+			return
+		}
+		// this render covers a refresh requested while waiting
+		select {
+		case <-w.forced:
+		default:
+		}
+		w.render(store)
+		timer.Reset(w.next())
+	}
+}
+
+// Scheduler runs one worker per instance and collects their blocks in a
+// BlockStore.
+type Scheduler struct {
+	store    *BlockStore
+	workers  []*worker
+	gate     *gate
+	watchers sync.WaitGroup
+	// only render the blocks without running Watch of updaters
+	renderOnly bool
+}
+
+func (s *Scheduler) newWorker(c configuredInstance) *worker {
+	return &worker{
+		instance:   c.instance,
+		config:     c.config,
+		overrides:  c.overrides,
+		errors:     c.errors,
+		thresholds: c.thresholds,
+		timeout:    c.timeout,
+		refresh:    c.interval,
+		signal:     c.signal,
+		variant:    c.variant,
+		clicks:     make(chan modules.ClickEvent, 1),
+		updates:    make(chan rendered),
+		forced:     make(chan struct{}, 1),
+		gate:       s.gate,
+		watchers:   &s.watchers,
+		done:       make(chan struct{}),
+	}
+}
+
+func NewScheduler(instances []configuredInstance) *Scheduler {
+	s := &Scheduler{
+		store: NewBlockStore(),
+		gate:  newGate(),
+	}
+	for _, c := range instances {
+		s.workers = append(s.workers, s.newWorker(c))
+	}
+	s.store.setWorkers(s.workers)
+	return s
+}
+
+func (s *Scheduler) Start() {
+	for _, w := range s.workers {
+		go w.run(s.store, !s.renderOnly)
+	}
+}
+
+// Reload replaces the running workers by the given instances. Workers whose
+// config didn't change keep running along with their state, all others are
+// stopped or started.
+func (s *Scheduler) Reload(instances []configuredInstance) {
+	old := make(map[string]*worker)
+	for _, w := range s.workers {
+		old[w.instance.Name()] = w
+	}
+
+	var workers, started []*worker
+	for _, c := range instances {
+		name := c.instance.Name()
+		if w, ok := old[name]; ok && reflect.DeepEqual(w.config, c.config) {
+			delete(old, name)
+			workers = append(workers, w)
+			continue
+		}
+		w := s.newWorker(c)
+		workers = append(workers, w)
+		started = append(started, w)
+	}
+
+	s.store.setWorkers(workers)
+	s.workers = workers
+	for _, w := range old {
+		close(w.done)
+	}
+	for _, w := range started {
+		go w.run(s.store, !s.renderOnly)
+	}
+	log.Info(fmt.Sprintf("Reloaded config: %d blocks kept, %d started, %d stopped",
+		len(workers)-len(started), len(started), len(old)))
+}
+
+// longest time Stop waits for updaters to clean up
+const stopTimeout = 2 * time.Second
+
+// Stop stops all workers and gives updaters a moment to clean up, e.g. to
+// kill the processes they started.
+func (s *Scheduler) Stop() {
+	for _, w := range s.workers {
+		close(w.done)
+	}
+	s.workers = nil
+
+	stopped := make(chan struct{})
+	go func() {
+		s.watchers.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(stopTimeout):
+		log.Warning("Timed out waiting for updaters to stop")
+	}
+}
+
+// Pause stops all rendering until Resume is called.
+func (s *Scheduler) Pause() {
+	log.Info("Pausing")
+	s.gate.Close()
+}
+
+// Resume continues rendering and refreshes every block right away, they
+// are likely outdated.
+func (s *Scheduler) Resume() {
+	log.Info("Resuming")
+	s.gate.Open()
+	s.RefreshAll()
+}
+
+// RefreshAll renders every block right away.
+func (s *Scheduler) RefreshAll() {
+	for _, w := range s.workers {
+		w.requestRefresh()
+	}
+}
+
+// Signal refreshes the blocks configured to be refreshed on SIGRTMIN+n.
+func (s *Scheduler) Signal(n int) {
+	for _, w := range s.workers {
+		if w.signal == n {
+			w.requestRefresh()
+		}
+	}
+}
+
+// worker returns the worker of the named block.
+func (s *Scheduler) worker(name string) (*worker, error) {
+	for _, w := range s.workers {
+		if w.instance.Name() == name {
+			return w, nil
+		}
+	}
+	return nil, errors.New("unknown block: " + name)
+}
+
+// Refresh renders the named block right away.
+func (s *Scheduler) Refresh(name string) error {
+	w, err := s.worker(name)
+	if err == nil {
+		w.requestRefresh()
+	}
+	return err
+}
+
+// SetHidden hides or shows the named block.
+func (s *Scheduler) SetHidden(name string, hidden bool) error {
+	w, err := s.worker(name)
+	if err == nil {
+		s.store.update(w, func(slot *slot) {
+			slot.hidden = hidden
+		})
+	}
+	return err
+}
+
+// SetText shows text instead of the full_text of the named block for the
+// given duration. An empty text removes it again.
+func (s *Scheduler) SetText(name string, text string, duration time.Duration) error {
+	w, err := s.worker(name)
+	if err != nil {
+		return err
+	}
+	s.store.update(w, func(slot *slot) {
+		slot.text = text
+		slot.textUntil = time.Now().Add(duration)
+	})
+	// emit the bar again once the text expired
+	time.AfterFunc(duration, s.store.notify)
+	return nil
+}
+
+// SetVariant recreates the named block with the settings of one of its
+// variants applied, an empty variant restores the plain config.
+func (s *Scheduler) SetVariant(name string, variant string, mods map[string]modules.Module) error {
+	w, err := s.worker(name)
+	if err != nil {
+		return err
+	}
+
+	element := make(map[string]interface{}, len(w.config))
+	for key, value := range w.config {
+		element[key] = value
+	}
+	if variant != "" {
+		variants, _ := w.config["variants"].(map[string]interface{})
+		settings, ok := variants[variant].(map[string]interface{})
+		if !ok {
+			return errors.New(name + " has no variant " + variant)
+		}
+		for key, value := range settings {
+			element[key] = value
+		}
+	}
+
+	c, err := configureBlock(element, mods)
+	if err != nil {
+		return err
+	}
+	// a reload compares against the plain config
+	c.config = w.config
+	c.variant = variant
+
+	replacement := s.newWorker(c)
+	for i := range s.workers {
+		if s.workers[i] == w {
+			s.workers[i] = replacement
+		}
+	}
+	s.store.replaceWorker(w, replacement)
+	close(w.done)
+	go replacement.run(s.store, !s.renderOnly)
+	return nil
+}
+
+// blockState is the state of a block as reported by the dump command.
+type blockState struct {
+	Name    string         `json:"name"`
+	Module  interface{}    `json:"module"`
+	Hidden  bool           `json:"hidden"`
+	Variant string         `json:"variant,omitempty"`
+	Text    string         `json:"text,omitempty"`
+	Block   *modules.Block `json:"block"`
+}
+
+// Dump returns the state of all blocks in bar order.
+func (s *Scheduler) Dump() (states []blockState) {
+	s.store.Lock()
+	defer s.store.Unlock()
+	now := time.Now()
+	for _, w := range s.store.workers {
+		slot := s.store.slots[w]
+		state := blockState{
+			Name:    w.instance.Name(),
+			Module:  w.config["module"],
+			Hidden:  slot.hidden,
+			Variant: w.variant,
+			Block:   slot.block,
+		}
+		if slot.text != "" && now.Before(slot.textUntil) {
+			state.Text = slot.text
+		}
+		states = append(states, state)
+	}
+	return
+}
+
+func (s *Scheduler) Store() *BlockStore {
+	return s.store
+}
+
+// Click forwards the event to the worker owning the clicked block.
+func (s *Scheduler) Click(event modules.ClickEvent) {
+	for _, w := range s.workers {
+		if w.instance.Name() != event.Name {
+			continue
+		}
+		if _, ok := w.instance.(modules.ClickHandler); !ok {
+			return
+		}
+		log.Debug("Click on " + event.Name)
+		select {
+		case w.clicks <- event:
+		default:
+			log.Warning("Dropping click on busy block: " + event.Name)
+		}
+		return
+	}
+	log.Warning("Click on unknown block: " + event.Name)
+}
@@ -0,0 +1,432 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	modules "github.com/andir/go3status/modules"
+	"github.com/andir/go3status/output"
+	"github.com/op/go-logging"
+)
+
+func TestMain(m *testing.M) {
+	// failing and panicking instances are logged on purpose
+	logging.SetBackend(logging.NewLogBackend(io.Discard, "", 0))
+	os.Exit(m.Run())
+}
+
+// fakeInstance renders whatever its render function returns and counts
+// the renders.
+type fakeInstance struct {
+	name     string
+	interval time.Duration
+	renders  int32
+	render   func(ctx context.Context, n int) (*modules.Block, error)
+}
+
+func (f *fakeInstance) Name() string {
+	return f.name
+}
+
+func (f *fakeInstance) String() string {
+	return f.name
+}
+
+func (f *fakeInstance) RefreshInterval() time.Duration {
+	if f.interval == 0 {
+		return time.Hour
+	}
+	return f.interval
+}
+
+func (f *fakeInstance) Render(ctx context.Context) (*modules.Block, error) {
+	n := int(atomic.AddInt32(&f.renders, 1))
+	if f.render == nil {
+		return &modules.Block{Name: f.name, Full_text: f.name}, nil
+	}
+	return f.render(ctx, n)
+}
+
+func (f *fakeInstance) Renders() int {
+	return int(atomic.LoadInt32(&f.renders))
+}
+
+// textBlock renders the number of the render, e.g. "a 2".
+func textBlock(name string, n int) *modules.Block {
+	return &modules.Block{Name: name, Full_text: name + " " + string(rune('0'+n))}
+}
+
+func fakeConfigured(instance modules.ModuleInstance, config map[string]interface{}) configuredInstance {
+	if config == nil {
+		config = map[string]interface{}{"name": instance.Name()}
+	}
+	return configuredInstance{
+		instance: instance,
+		config:   config,
+		errors:   defaultErrorSettings,
+		timeout:  time.Second,
+	}
+}
+
+// startScheduler runs a scheduler for the instances until the test ends.
+func startScheduler(t *testing.T, instances ...configuredInstance) *Scheduler {
+	s := NewScheduler(instances)
+	s.Start()
+	t.Cleanup(s.Stop)
+	return s
+}
+
+// eventually fails the test unless cond turns true within a second.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for " + what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// texts returns the full_text of the blocks, "-" for hidden ones.
+func texts(blocks []*modules.Block) string {
+	var texts []string
+	for _, block := range blocks {
+		if block == nil {
+			texts = append(texts, "-")
+		} else {
+			texts = append(texts, block.Full_text)
+		}
+	}
+	return strings.Join(texts, ",")
+}
+
+func blockTexts(s *Scheduler) func() string {
+	return func() string { return texts(s.Store().Blocks()) }
+}
+
+func TestSchedulerRendersInstancesIndependently(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	slow := &fakeInstance{name: "slow", render: func(ctx context.Context, n int) (*modules.Block, error) {
+		<-release
+		return nil, nil
+	}}
+	fast := &fakeInstance{name: "fast"}
+	s := startScheduler(t, fakeConfigured(slow, nil), fakeConfigured(fast, nil))
+
+	get := blockTexts(s)
+	eventually(t, "the fast block", func() bool { return get() == "-,fast" })
+	if s.Store().Complete() {
+		t.Error("store is complete while the slow instance is rendering")
+	}
+}
+
+func TestSchedulerRefresh(t *testing.T) {
+	a := &fakeInstance{name: "a", render: func(ctx context.Context, n int) (*modules.Block, error) {
+		return textBlock("a", n), nil
+	}}
+	b := &fakeInstance{name: "b", render: func(ctx context.Context, n int) (*modules.Block, error) {
+		return textBlock("b", n), nil
+	}}
+	signaled := fakeConfigured(b, nil)
+	signaled.signal = 3
+	s := startScheduler(t, fakeConfigured(a, nil), signaled)
+	get := blockTexts(s)
+	eventually(t, "the first render", func() bool { return get() == "a 1,b 1" })
+
+	if err := s.Refresh("a"); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "the refresh of a", func() bool { return get() == "a 2,b 1" })
+
+	s.Signal(2)
+	s.Signal(3)
+	eventually(t, "the refresh of b", func() bool { return get() == "a 2,b 2" })
+
+	s.RefreshAll()
+	eventually(t, "the refresh of all blocks", func() bool { return get() == "a 3,b 3" })
+
+	if err := s.Refresh("c"); err == nil {
+		t.Error("refreshing an unknown block didn't fail")
+	}
+}
+
+func TestSchedulerInterval(t *testing.T) {
+	a := &fakeInstance{name: "a", interval: 10 * time.Millisecond}
+	startScheduler(t, fakeConfigured(a, nil))
+	eventually(t, "renders on the interval", func() bool { return a.Renders() >= 3 })
+}
+
+func TestSchedulerTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	hang := func(ctx context.Context, n int) (*modules.Block, error) {
+		if n == 1 {
+			return &modules.Block{Full_text: "ok", Color: "#FFFFFF"}, nil
+		}
+		// ignores the context like a stuck instance
+		<-release
+		return nil, nil
+	}
+	stale := &fakeInstance{name: "stale", render: hang}
+	failing := &fakeInstance{name: "failing", render: func(ctx context.Context, n int) (*modules.Block, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}}
+	c := fakeConfigured(stale, nil)
+	c.timeout = 20 * time.Millisecond
+	f := fakeConfigured(failing, nil)
+	f.timeout = 20 * time.Millisecond
+	s := startScheduler(t, c, f)
+	get := blockTexts(s)
+
+	eventually(t, "the first render", func() bool { return strings.HasPrefix(get(), "ok,") })
+	s.Refresh("stale")
+	eventually(t, "the stale block", func() bool {
+		block := s.Store().Blocks()[0]
+		return block.Full_text == "ok" && block.Color == defaultErrorSettings.stale_color
+	})
+	if n := stale.Renders(); n != 2 {
+		t.Errorf("%d renders, want 2", n)
+	}
+
+	// without a good block there is nothing to show as stale
+	eventually(t, "the error block", func() bool {
+		return strings.HasSuffix(get(), ",failing: context deadline exceeded")
+	})
+}
+
+func TestSchedulerErrors(t *testing.T) {
+	instance := &fakeInstance{name: "a", render: func(ctx context.Context, n int) (*modules.Block, error) {
+		switch n {
+		case 1:
+			panic("boom")
+		case 2, 3:
+			return nil, errors.New("broken")
+		}
+		return textBlock("a", n), nil
+	}}
+	c := fakeConfigured(instance, nil)
+	c.overrides = map[string]interface{}{"color": "#00FF00"}
+	c.errors.urgent_after = 3
+	s := startScheduler(t, c)
+	block := func() *modules.Block { return s.Store().Blocks()[0] }
+
+	tests := []struct {
+		text   string
+		color  string
+		urgent bool
+	}{
+		{"a: panic: boom", defaultErrorSettings.color, false},
+		{"a: broken", defaultErrorSettings.color, false},
+		{"a: broken", defaultErrorSettings.color, true},
+		{"a 4", "#00FF00", false},
+	}
+	for i, test := range tests {
+		eventually(t, test.text, func() bool {
+			b := block()
+			return b != nil && b.Full_text == test.text && b.Color == test.color && b.Urgent == test.urgent
+		})
+		if i < len(tests)-1 {
+			s.Refresh("a")
+		}
+	}
+}
+
+func TestSchedulerPause(t *testing.T) {
+	a := &fakeInstance{name: "a", render: func(ctx context.Context, n int) (*modules.Block, error) {
+		return textBlock("a", n), nil
+	}}
+	s := startScheduler(t, fakeConfigured(a, nil))
+	get := blockTexts(s)
+	eventually(t, "the first render", func() bool { return get() == "a 1" })
+
+	s.Pause()
+	s.RefreshAll()
+	time.Sleep(50 * time.Millisecond)
+	if n := a.Renders(); n != 1 {
+		t.Fatalf("%d renders while paused, want 1", n)
+	}
+
+	// resuming refreshes right away, the pending request is merged
+	s.Resume()
+	eventually(t, "the render after resuming", func() bool { return get() == "a 2" })
+	time.Sleep(20 * time.Millisecond)
+	if n := a.Renders(); n != 2 {
+		t.Errorf("%d renders after resuming, want 2", n)
+	}
+}
+
+func TestSchedulerReload(t *testing.T) {
+	var mu sync.Mutex
+	stopped := make(map[string]bool)
+	newInstance := func(name string) *fakeInstance {
+		return &fakeInstance{name: name, render: func(ctx context.Context, n int) (*modules.Block, error) {
+			return textBlock(name, n), nil
+		}}
+	}
+	a, b, c := newInstance("a"), newInstance("b"), newInstance("c")
+	s := startScheduler(t,
+		fakeConfigured(a, map[string]interface{}{"name": "a", "module": "time"}),
+		fakeConfigured(b, map[string]interface{}{"name": "b", "module": "time"}),
+		fakeConfigured(c, map[string]interface{}{"name": "c", "module": "time"}),
+	)
+	get := blockTexts(s)
+	eventually(t, "the first render", func() bool { return get() == "a 1,b 1,c 1" })
+	s.Refresh("a")
+	eventually(t, "the refresh of a", func() bool { return get() == "a 2,b 1,c 1" })
+	oldA, oldB, oldC := s.workers[0], s.workers[1], s.workers[2]
+	for name, w := range map[string]*worker{"b": oldB, "c": oldC} {
+		name, w := name, w
+		go func() {
+			<-w.done
+			mu.Lock()
+			stopped[name] = true
+			mu.Unlock()
+		}()
+	}
+
+	// a is kept with its state, b changed and d is new, c is gone
+	newB, d := newInstance("b"), newInstance("d")
+	s.Reload([]configuredInstance{
+		fakeConfigured(d, map[string]interface{}{"name": "d", "module": "time"}),
+		fakeConfigured(newInstance("a"), map[string]interface{}{"name": "a", "module": "time"}),
+		fakeConfigured(newB, map[string]interface{}{"name": "b", "module": "time", "color": "#FFFFFF"}),
+	})
+
+	eventually(t, "the new blocks", func() bool { return get() == "d 1,a 2,b 1" })
+	if s.workers[1] != oldA {
+		t.Error("the unchanged worker was replaced")
+	}
+	if s.workers[2] == oldB {
+		t.Error("the changed worker was kept")
+	}
+	if n := a.Renders(); n != 2 {
+		t.Errorf("the kept instance rendered %d times, want 2", n)
+	}
+	eventually(t, "the old workers to stop", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return stopped["b"] && stopped["c"]
+	})
+}
+
+func TestBlockStore(t *testing.T) {
+	s := NewBlockStore()
+	a := &worker{instance: &fakeInstance{name: "a"}}
+	b := &worker{instance: &fakeInstance{name: "b"}}
+	s.setWorkers([]*worker{a, b})
+	<-s.Changed()
+
+	if s.Complete() {
+		t.Error("complete without any block")
+	}
+	s.Set(a, &modules.Block{Full_text: "a"})
+	if s.Complete() {
+		t.Error("complete with one of two blocks")
+	}
+	// a hidden block counts as rendered
+	s.Set(b, nil)
+	if !s.Complete() {
+		t.Error("not complete with all blocks")
+	}
+	if got := texts(s.Blocks()); got != "a,-" {
+		t.Errorf("blocks = %s", got)
+	}
+
+	// changes arriving before the main loop looked are merged into one
+	// notification
+	select {
+	case <-s.Changed():
+	default:
+		t.Fatal("no notification")
+	}
+	select {
+	case <-s.Changed():
+		t.Fatal("more than one notification")
+	default:
+	}
+
+	// removed workers can't publish anymore
+	s.setWorkers([]*worker{b})
+	<-s.Changed()
+	s.Set(a, &modules.Block{Full_text: "late"})
+	select {
+	case <-s.Changed():
+		t.Error("notified about a removed worker")
+	default:
+	}
+
+	s.SetNotice(&modules.Block{Full_text: "notice"})
+	if got := texts(s.Blocks()); got != "notice,-" {
+		t.Errorf("blocks = %s", got)
+	}
+}
+
+// updatingInstance pushes a block as soon as Watch runs.
+type updatingInstance struct {
+	fakeInstance
+	watches int32
+}
+
+func (u *updatingInstance) Watch(update modules.UpdateFunc, refresh modules.RefreshFunc, done <-chan struct{}) {
+	atomic.AddInt32(&u.watches, 1)
+	update(&modules.Block{Full_text: "pushed"}, nil)
+	<-done
+}
+
+func (u *updatingInstance) Watches() int {
+	return int(atomic.LoadInt32(&u.watches))
+}
+
+func TestSchedulerUpdater(t *testing.T) {
+	u := &updatingInstance{fakeInstance: fakeInstance{name: "u"}}
+	s := startScheduler(t, fakeConfigured(u, nil))
+	eventually(t, "the pushed block", func() bool { return blockTexts(s)() == "pushed" })
+	if n := u.Watches(); n != 1 {
+		t.Errorf("%d watches, want 1", n)
+	}
+}
+
+func TestRenderOnce(t *testing.T) {
+	slow := &fakeInstance{name: "slow", render: func(ctx context.Context, n int) (*modules.Block, error) {
+		time.Sleep(20 * time.Millisecond)
+		return &modules.Block{Full_text: "slow"}, nil
+	}}
+	hidden := &fakeInstance{name: "hidden", render: func(ctx context.Context, n int) (*modules.Block, error) {
+		return nil, nil
+	}}
+	u := &updatingInstance{fakeInstance: fakeInstance{name: "u"}}
+	backend, err := output.New("plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	instances := []configuredInstance{fakeConfigured(slow, nil), fakeConfigured(hidden, nil), fakeConfigured(u, nil)}
+	if err := renderOnce(&out, instances, output.Header{}, backend); err != nil {
+		t.Fatal(err)
+	}
+	// the update waits for the slow block and shows the rendered one
+	// instead of what the updater would push
+	if got := out.String(); got != "slow | u\n" {
+		t.Errorf("output = %q", got)
+	}
+	if n := u.Watches(); n != 0 {
+		t.Errorf("%d watches, want none", n)
+	}
+	for _, instance := range []*fakeInstance{slow, hidden, &u.fakeInstance} {
+		if n := instance.Renders(); n != 1 {
+			t.Errorf("%s rendered %d times, want once", instance.name, n)
+		}
+	}
+}
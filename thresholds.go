@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+
+	modules "github.com/andir/go3status/modules"
+)
+
+// thresholdsSchema lists the keys of the thresholds object of a block.
+var thresholdsSchema = modules.Schema{
+	"metric":         {Type: modules.String, Required: true, Doc: "metric of the module the colors depend on"},
+	"degraded":       {Type: modules.Number, Required: true, Doc: "value from which the block is degraded, below bad for metrics where low values are bad"},
+	"bad":            {Type: modules.Number, Required: true, Doc: "value from which the block is bad"},
+	"urgent":         {Type: modules.Bool, Doc: "mark the block urgent while it is bad"},
+	"good_color":     {Type: modules.String, Doc: "overrides good_color of general"},
+	"degraded_color": {Type: modules.String, Doc: "overrides degraded_color of general"},
+	"bad_color":      {Type: modules.String, Doc: "overrides bad_color of general"},
+}
+
+// thresholds color a block by one of the metrics its module reports.
+type thresholds struct {
+	metric   string
+	degraded float64
+	bad      float64
+	urgent   bool
+	// colors replacing those of the palette, empty ones aren't replaced
+	colors modules.Palette
+}
+
+// parseThresholds reads the thresholds of a block that passed
+// validateConfig, nil if there are none.
+func parseThresholds(config map[string]interface{}) (t *thresholds, err error) {
+	v, ok := config["thresholds"]
+	if !ok {
+		return
+	}
+	settings, ok := v.(map[string]interface{})
+	if !ok {
+		err = &modules.FieldError{Key: "thresholds", Err: errors.New("must be an object")}
+		return
+	}
+
+	t = new(thresholds)
+	t.metric, _ = settings["metric"].(string)
+	t.urgent, _ = settings["urgent"].(bool)
+	t.colors.Good, _ = settings["good_color"].(string)
+	t.colors.Degraded, _ = settings["degraded_color"].(string)
+	t.colors.Bad, _ = settings["bad_color"].(string)
+	if t.degraded, ok = modules.ToFloat(settings["degraded"]); !ok {
+		err = &modules.FieldError{Key: "thresholds.degraded", Err: errors.New("must be a number")}
+	} else if t.bad, ok = modules.ToFloat(settings["bad"]); !ok {
+		err = &modules.FieldError{Key: "thresholds.bad", Err: errors.New("must be a number")}
+	}
+	return
+}
+
+// apply colors the block according to its metric. Blocks that didn't
+// report the metric are left alone.
+func (t *thresholds) apply(block *modules.Block) {
+	if t == nil || block == nil {
+		return
+	}
+	value, ok := block.Metrics[t.metric]
+	if !ok {
+		return
+	}
+
+	palette := modules.CurrentPalette()
+	if t.colors.Good != "" {
+		palette.Good = t.colors.Good
+	}
+	if t.colors.Degraded != "" {
+		palette.Degraded = t.colors.Degraded
+	}
+	if t.colors.Bad != "" {
+		palette.Bad = t.colors.Bad
+	}
+
+	level := modules.ThresholdLevel(value, t.degraded, t.bad)
+	block.Color = palette.Color(level)
+	if t.urgent && level == modules.Bad {
+		block.Urgent = true
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	modules "github.com/andir/go3status/modules"
+)
+
+// coreSchema lists the keys handled by the core for every block, in
+// addition to modules.BlockSchema and the schema of the module.
+var coreSchema = modules.Schema{
+	"name":               {Type: modules.String, Required: true, Doc: "unique name of the block"},
+	"module":             {Type: modules.String, Required: true, Doc: "module rendering the block"},
+	"interval":           {Type: modules.Duration, Doc: "time between two renders"},
+	"timeout":            {Type: modules.Duration, Default: "10s", Doc: "longest time a render may take"},
+	"error_color":        {Type: modules.String, Default: "#FF0000", Doc: "color of the block when rendering failed"},
+	"error_urgent_after": {Type: modules.Int, Default: 3, Doc: "failures in a row after which the block turns urgent"},
+	"stale_color":        {Type: modules.String, Default: "#888888", Doc: "color of the last good block while rendering times out"},
+	"signal":             {Type: modules.Int, Doc: "refresh the block on SIGRTMIN+signal, between 1 and 30"},
+	"variants":           {Type: modules.Object, Doc: "named sets of settings that can be switched to with go3status ctl variant"},
+	"thresholds":         {Type: modules.Object, Doc: "color the block by a metric of its module"},
+}
+
+// problem is a single finding of validateConfig.
+type problem struct {
+	// position of the block in the config, -1 for problems of the config
+	// as a whole
+	index   int
+	name    string
+	key     string
+	message string
+	warning bool
+}
+
+func (p problem) String() (s string) {
+	if p.index >= 0 {
+		s = fmt.Sprintf("blocks[%d]", p.index)
+		if p.name != "" {
+			s += " (" + p.name + ")"
+		}
+	} else {
+		s = "config"
+	}
+	if p.key != "" {
+		s += fmt.Sprintf(" %q", p.key)
+	}
+	s += ": " + p.message
+	if p.warning {
+		s = "warning: " + s
+	}
+	return
+}
+
+// sortedKeys returns the keys of a config map or schema in order.
+func sortedKeys(m interface{}) (keys []string) {
+	for _, key := range reflect.ValueOf(m).MapKeys() {
+		keys = append(keys, key.String())
+	}
+	sort.Strings(keys)
+	return
+}
+
+func hasErrors(problems []problem) bool {
+	for _, p := range problems {
+		if !p.warning {
+			return true
+		}
+	}
+	return false
+}
+
+// checkKeys checks the keys of a block against the core, block and module
+// schemas. metrics are those the module reports.
+func checkKeys(element map[string]interface{}, schema modules.Schema, metrics []string, prefix string, report func(key, message string, warning bool)) {
+	for _, key := range sortedKeys(element) {
+		field, ok := coreSchema[key]
+		if !ok {
+			field, ok = modules.BlockSchema[key]
+		}
+		if !ok {
+			field, ok = schema[key]
+		}
+		if !ok {
+			report(prefix+key, "unknown key", true)
+			continue
+		}
+		if err := field.Check(element[key]); err != nil {
+			report(prefix+key, err.Error(), false)
+		} else if key == "signal" {
+			if n, _ := modules.ToInt(element[key]); n < 1 || n > maxRealtimeSignal {
+				report(prefix+key, fmt.Sprintf("must be between 1 and %d", maxRealtimeSignal), false)
+			}
+		} else if key == "thresholds" {
+			checkThresholds(element[key].(map[string]interface{}), metrics, prefix+key+".", report)
+		}
+	}
+}
+
+// checkThresholds checks the thresholds of a block.
+func checkThresholds(settings map[string]interface{}, metrics []string, prefix string, report func(key, message string, warning bool)) {
+	for _, key := range sortedKeys(thresholdsSchema) {
+		if _, ok := settings[key]; !ok && thresholdsSchema[key].Required {
+			report(prefix+key, "missing", false)
+		}
+	}
+	for _, key := range sortedKeys(settings) {
+		field, ok := thresholdsSchema[key]
+		if !ok {
+			report(prefix+key, "unknown key", true)
+		} else if err := field.Check(settings[key]); err != nil {
+			report(prefix+key, err.Error(), false)
+		}
+	}
+
+	metric, ok := settings["metric"].(string)
+	if !ok {
+		return
+	}
+	for _, m := range metrics {
+		if m == metric {
+			return
+		}
+	}
+	if len(metrics) == 0 {
+		report(prefix+"metric", "the module reports no metrics", false)
+	} else {
+		report(prefix+"metric", "unknown metric "+metric+", one of "+strings.Join(metrics, ", "), false)
+	}
+}
+
+// tryConfigure creates the instance of a block that passed the schema checks
+// and reports why that failed, if it did.
+func tryConfigure(element map[string]interface{}, mods map[string]modules.Module, prefix string, report func(key, message string, warning bool)) {
+	_, err := configureBlock(element, mods)
+	if err == nil {
+		return
+	}
+	var fieldErr *modules.FieldError
+	if errors.As(err, &fieldErr) {
+		report(prefix+fieldErr.Key, fieldErr.Err.Error(), false)
+	} else {
+		report(strings.TrimSuffix(prefix, "."), err.Error(), false)
+	}
+}
+
+// validateConfig checks every block against the schemas. Unknown keys are
+// reported as warnings, everything else as errors.
+func validateConfig(config *Config, mods map[string]modules.Module) (problems []problem) {
+	names := make(map[string]int)
+
+	for index, block := range config.Blocks {
+		element := config.blockConfig(block)
+		report := func(key, message string, warning bool) {
+			name, _ := element["name"].(string)
+			problems = append(problems, problem{index, name, key, message, warning})
+		}
+		start := len(problems)
+
+		schema := modules.Schema{}
+		var metrics []string
+		if name, ok := element["module"].(string); ok {
+			if mod, ok := mods[name]; ok {
+				schema = mod.Schema()
+				metrics = mod.Metrics
+			} else {
+				report("module", "unknown module "+name, false)
+			}
+		}
+
+		for _, s := range []modules.Schema{coreSchema, schema} {
+			for _, key := range sortedKeys(s) {
+				if _, ok := element[key]; !ok && s[key].Required {
+					report(key, "missing", false)
+				}
+			}
+		}
+
+		checkKeys(element, schema, metrics, "", report)
+		// the modules find problems the schemas can't express, like
+		// templates that don't parse
+		if !hasErrors(problems[start:]) {
+			tryConfigure(element, mods, "", report)
+		}
+
+		if variants, ok := element["variants"].(map[string]interface{}); ok {
+			for _, variant := range sortedKeys(variants) {
+				prefix := "variants." + variant + "."
+				settings, ok := variants[variant].(map[string]interface{})
+				if !ok {
+					report(prefix[:len(prefix)-1], "must be an object", false)
+					continue
+				}
+				variantStart := len(problems)
+				checked := make(map[string]interface{})
+				for _, key := range sortedKeys(settings) {
+					switch key {
+					case "name", "module", "variants":
+						report(prefix+key, "can't be changed by a variant", false)
+					default:
+						checked[key] = settings[key]
+					}
+				}
+				checkKeys(checked, schema, metrics, prefix, report)
+				if !hasErrors(problems[start:variantStart]) && !hasErrors(problems[variantStart:]) {
+					merged := make(map[string]interface{}, len(element))
+					for key, value := range element {
+						merged[key] = value
+					}
+					for key, value := range checked {
+						merged[key] = value
+					}
+					tryConfigure(merged, mods, prefix, report)
+				}
+			}
+		}
+
+		if name, ok := element["name"].(string); ok {
+			if first, ok := names[name]; ok {
+				report("name", fmt.Sprintf("duplicate name, already used by blocks[%d]", first), false)
+			} else {
+				names[name] = index
+			}
+		}
+	}
+
+	if len(config.Blocks) == 0 {
+		problems = append(problems, problem{index: -1, message: "no blocks configured"})
+	}
+	return
+}
+
+// checkConfig validates the config file, prints all problems and returns
+// the exit code for the check subcommand.
+func checkConfig(fileName string, mods map[string]modules.Module) int {
+	config, err := readConfig(fileName)
+	if fileName == "" {
+		fileName = "default config"
+	}
+	if err != nil {
+		fmt.Println(fileName + ": " + err.Error())
+		return 1
+	}
+
+	problems := validateConfig(config, mods)
+	for _, p := range problems {
+		fmt.Println(fileName + ": " + p.String())
+	}
+	if hasErrors(problems) {
+		return 1
+	}
+
+	fmt.Println(fileName + ": OK")
+	return 0
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestValidateConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{
+			name: "valid",
+			text: `{"defaults": {"load": {"interval": "3s"}}, "blocks": [
+				{"name": "a", "module": "time", "color": "#FFFFFF", "variants": {"short": {"format": "15:04"}}},
+				{"name": "b", "module": "load", "thresholds": {"metric": "load1", "degraded": 1, "bad": 2}}
+			]}`,
+		},
+		{
+			name: "no blocks",
+			text: `{"blocks": []}`,
+			want: []string{"config: no blocks configured"},
+		},
+		{
+			name: "duplicate names",
+			text: `[{"name": "a", "module": "time"}, {"name": "b", "module": "time"}, {"name": "a", "module": "load"}]`,
+			want: []string{`blocks[2] (a) "name": duplicate name, already used by blocks[0]`},
+		},
+		{
+			name: "unknown keys are warnings",
+			text: `[{"name": "a", "module": "time", "colour": "#FFFFFF", "thresholds": {"metric": "x", "bda": 1}}]`,
+			want: []string{
+				`warning: blocks[0] (a) "colour": unknown key`,
+				`blocks[0] (a) "thresholds.bad": missing`,
+				`blocks[0] (a) "thresholds.degraded": missing`,
+				`warning: blocks[0] (a) "thresholds.bda": unknown key`,
+				`blocks[0] (a) "thresholds.metric": the module reports no metrics`,
+			},
+		},
+		{
+			name: "missing and unknown",
+			text: `[{"module": "time"}, {"name": "b", "module": "clock"}, {"name": "c"}]`,
+			want: []string{
+				`blocks[0] "name": missing`,
+				`blocks[1] (b) "module": unknown module clock`,
+				`blocks[2] (c) "module": missing`,
+			},
+		},
+		{
+			name: "wrong types",
+			text: `[{"name": "a", "module": "time", "interval": 5, "signal": 31, "separator": "no", "markup": "html"}]`,
+			want: []string{
+				`blocks[0] (a) "interval": must be a duration string like "3s"`,
+				`blocks[0] (a) "markup": must be one of pango, none`,
+				`blocks[0] (a) "separator": must be true or false`,
+				`blocks[0] (a) "signal": must be between 1 and 30`,
+			},
+		},
+		{
+			name: "configure failures",
+			text: `[{"name": "a", "module": "load", "format": "{{ .Load1"}, {"name": "b", "module": "time", "timeout": "-1s"}]`,
+			want: []string{
+				`blocks[0] (a) "format": template: a:1: unclosed action`,
+				`blocks[1] (b) "timeout": must be positive`,
+			},
+		},
+		{
+			name: "bad variants",
+			text: `[{"name": "a", "module": "load", "variants": {
+				"list": [],
+				"renamed": {"name": "b", "module": "time"},
+				"typo": {"colour": "#FFFFFF"},
+				"wrong": {"interval": 5},
+				"broken": {"format": "{{ .Load1"}
+			}}]`,
+			want: []string{
+				`blocks[0] (a) "variants.broken.format": template: a:1: unclosed action`,
+				`blocks[0] (a) "variants.list": must be an object`,
+				`blocks[0] (a) "variants.renamed.module": can't be changed by a variant`,
+				`blocks[0] (a) "variants.renamed.name": can't be changed by a variant`,
+				`warning: blocks[0] (a) "variants.typo.colour": unknown key`,
+				`blocks[0] (a) "variants.wrong.interval": must be a duration string like "3s"`,
+			},
+		},
+		{
+			name: "variants of a broken block aren't configured",
+			text: `[{"name": "a", "module": "load", "interval": 5, "variants": {"short": {"format": "{{ .Load1 }}"}}}]`,
+			want: []string{`blocks[0] (a) "interval": must be a duration string like "3s"`},
+		},
+	}
+	mods := registeredModules()
+	for _, test := range tests {
+		config, err := loadConfig([]byte(test.text))
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		var got []string
+		for _, p := range validateConfig(config, mods) {
+			got = append(got, p.String())
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got problems\n%q\nwant\n%q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestCheckConfig(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		text string
+		want int
+	}{
+		{`[{"name": "a", "module": "time"}]`, 0},
+		{`[{"name": "a", "module": "time", "colour": "red"}]`, 0},
+		{`[{"name": "a", "module": "time"}, {"name": "a", "module": "time"}]`, 1},
+		{`{"version": 3}`, 1},
+	}
+
+	// keep the report out of the test output
+	stdout := os.Stdout
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = devNull
+	defer func() {
+		os.Stdout = stdout
+		devNull.Close()
+	}()
+
+	mods := registeredModules()
+	for i, test := range tests {
+		fileName := filepath.Join(dir, "config"+string(rune('0'+i))+".json")
+		if err := os.WriteFile(fileName, []byte(test.text), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if got := checkConfig(fileName, mods); got != test.want {
+			t.Errorf("checkConfig(%s) = %d, want %d", test.text, got, test.want)
+		}
+	}
+	if got := checkConfig(filepath.Join(dir, "missing.json"), mods); got != 1 {
+		t.Errorf("checkConfig of a missing file = %d, want 1", got)
+	}
+}